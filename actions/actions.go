@@ -0,0 +1,138 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tootedom/ec2-local-healthchecker/shell"
+)
+
+const (
+	// StatusHealthy is the status reported by an action for a healthy instance
+	StatusHealthy = "healthy"
+	// StatusUnhealthy is the status reported by an action for an unhealthy instance
+	StatusUnhealthy = "unhealthy"
+)
+
+// Action is the interface for reporting the health of the instance to
+// whatever is responsible for replacing it.
+type Action interface {
+	// SetHealthy reports the instance as healthy
+	SetHealthy() error
+	// SetUnhealthy reports the instance as unhealthy
+	SetUnhealthy() error
+}
+
+// SkippedError is returned by an Action that has chosen not to report the
+// health, for example because the AutoScaling group's health check processes
+// are suspended. The health should be reported again later.
+type SkippedError struct {
+	Reason string
+}
+
+// Error implements the error interface
+func (s SkippedError) Error() string {
+	return "skipped: " + s.Reason
+}
+
+// IsSkipped returns true if the error is a SkippedError
+func IsSkipped(err error) bool {
+	_, ok := err.(SkippedError)
+	return ok
+}
+
+// ActionFunc is a convenience type to create an Action from a single function
+// that is passed the status being reported
+type ActionFunc func(status string) error
+
+// SetHealthy implements the Action interface
+func (af ActionFunc) SetHealthy() error {
+	return af(StatusHealthy)
+}
+
+// SetUnhealthy implements the Action interface
+func (af ActionFunc) SetUnhealthy() error {
+	return af(StatusUnhealthy)
+}
+
+// NoopAction returns an Action that does nothing. It is used when running
+// standalone without any local action configured; the result of the checks is
+// only logged.
+func NoopAction() Action {
+	return ActionFunc(func(status string) error {
+		return nil
+	})
+}
+
+// ExecAction runs the given command through the shell, passing the status as
+// the first argument and in the EC2_LOCAL_HEALTHCHECKER_STATUS environment
+// variable. The command, and anything it started, is killed if it has not
+// finished within timeout.
+func ExecAction(command string, timeout time.Duration) Action {
+	return ActionFunc(func(status string) error {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		cmd := shell.Command(ctx, command, "sh", status)
+		cmd.Env = append(os.Environ(), "EC2_LOCAL_HEALTHCHECKER_STATUS="+status)
+		output, err := cmd.CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("command %q timed out after %s", command, timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("command %q failed: %v: %s", command, err, output)
+		}
+		return nil
+	})
+}
+
+// StatusFileAction writes the status, followed by the time it was reported,
+// to the file at path. The file is replaced atomically so readers never see a
+// partially written status.
+func StatusFileAction(path string) Action {
+	return ActionFunc(func(status string) error {
+		content := fmt.Sprintf("%s\n%s\n", status, time.Now().UTC().Format(time.RFC3339))
+		tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+		if err != nil {
+			return fmt.Errorf("unable to write status file %s: %v", path, err)
+		}
+		_, err = tmp.WriteString(content)
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(tmp.Name(), 0644)
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return fmt.Errorf("unable to write status file %s: %v", path, err)
+		}
+		return nil
+	})
+}
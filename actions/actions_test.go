@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecActionPassesStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	action := ExecAction("echo $1 $EC2_LOCAL_HEALTHCHECKER_STATUS > "+out, time.Second*5)
+
+	require.NoError(t, action.SetUnhealthy())
+	content, err := ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "unhealthy unhealthy\n", string(content))
+
+	require.NoError(t, action.SetHealthy())
+	content, err = ioutil.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "healthy healthy\n", string(content))
+}
+
+func TestExecActionFailures(t *testing.T) {
+	err := ExecAction("echo broken; exit 3", time.Second*5).SetHealthy()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+
+	err = ExecAction("exec sleep 5", time.Millisecond*100).SetHealthy()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestExecActionTimeoutKillsChildren(t *testing.T) {
+	// the shell waits for sleep, which holds the output pipe open
+	start := time.Now()
+	err := ExecAction("sleep 3; true", time.Millisecond*200).SetHealthy()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 200ms")
+	assert.True(t, time.Since(start) < 2*time.Second, "took %s", time.Since(start))
+}
+
+func TestStatusFileAction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "actions")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "status")
+	action := StatusFileAction(path)
+
+	require.NoError(t, action.SetUnhealthy())
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "unhealthy\n"))
+
+	require.NoError(t, action.SetHealthy())
+	content, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "healthy\n"))
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	assert.Error(t, StatusFileAction(filepath.Join(dir, "missing", "status")).SetHealthy())
+}
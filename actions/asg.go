@@ -0,0 +1,202 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package actions
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// AutoScaling sets the health of the instance in the AutoScaling group it is
+// attached to, and describes the group and the instance.
+type AutoScaling struct {
+	region     string
+	instanceID string
+	creds      *credentials.Credentials
+	endpoint   string
+	// RespectGracePeriod sets ShouldRespectGracePeriod when setting the
+	// instance health, so AutoScaling ignores it during the group's
+	// HealthCheckGracePeriod
+	RespectGracePeriod bool
+	// TargetLifecycleState, if set, returns the target lifecycle state of
+	// the instance from the instance metadata. It is used in preference to
+	// the lifecycle state described by AutoScaling.
+	TargetLifecycleState func() (string, error)
+	// Logger, if set, is used to log problems checking the group
+	Logger *log.Logger
+}
+
+// ASGAction returns an Action that calls AutoScaling SetInstanceHealth for
+// the given instance.
+func ASGAction(region string, instanceID string, creds *credentials.Credentials) *AutoScaling {
+	return &AutoScaling{region: region, instanceID: instanceID, creds: creds}
+}
+
+// SetHealthy implements the Action interface
+func (a *AutoScaling) SetHealthy() error {
+	return a.setInstanceHealth("Healthy")
+}
+
+// SetUnhealthy implements the Action interface
+func (a *AutoScaling) SetUnhealthy() error {
+	return a.setInstanceHealth("Unhealthy")
+}
+
+func (a *AutoScaling) session() (*session.Session, error) {
+	conf := &aws.Config{Credentials: a.creds, Region: aws.String(a.region)}
+	if a.endpoint != "" {
+		conf.Endpoint = aws.String(a.endpoint)
+	}
+	sess, err := session.NewSession(conf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create a AWS Session: %v", err)
+	}
+	return sess, nil
+}
+
+func (a *AutoScaling) setInstanceHealth(status string) error {
+	if reason := a.skipReason(); reason != "" {
+		return SkippedError{Reason: reason}
+	}
+	sess, err := a.session()
+	if err != nil {
+		return err
+	}
+	asg := autoscaling.New(sess, aws.NewConfig().WithRegion(a.region))
+	input := autoscaling.SetInstanceHealthInput{HealthStatus: aws.String(status), InstanceId: aws.String(a.instanceID)}
+	if a.RespectGracePeriod {
+		input.ShouldRespectGracePeriod = aws.Bool(true)
+	}
+	if _, err := asg.SetInstanceHealth(&input); err != nil {
+		return fmt.Errorf("unable to set instance(%s) as %s: %v", a.instanceID, status, err)
+	}
+	return nil
+}
+
+// describeGroup returns the AutoScaling group the instance is attached to,
+// and the instance's details within it
+func (a *AutoScaling) describeGroup() (*autoscaling.Group, *autoscaling.InstanceDetails, error) {
+	sess, err := a.session()
+	if err != nil {
+		return nil, nil, err
+	}
+	asg := autoscaling.New(sess, aws.NewConfig().WithRegion(a.region))
+	instances, err := asg.DescribeAutoScalingInstances(&autoscaling.DescribeAutoScalingInstancesInput{
+		InstanceIds: []*string{aws.String(a.instanceID)},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe instance(%s): %v", a.instanceID, err)
+	}
+	if len(instances.AutoScalingInstances) == 0 {
+		return nil, nil, fmt.Errorf("instance(%s) is not in an AutoScaling group", a.instanceID)
+	}
+	instance := instances.AutoScalingInstances[0]
+	groupName := instance.AutoScalingGroupName
+	groups, err := asg.DescribeAutoScalingGroups(&autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{groupName},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to describe AutoScaling group(%s): %v", aws.StringValue(groupName), err)
+	}
+	if len(groups.AutoScalingGroups) == 0 {
+		return nil, nil, fmt.Errorf("AutoScaling group(%s) not found", aws.StringValue(groupName))
+	}
+	return groups.AutoScalingGroups[0], instance, nil
+}
+
+// skipReason returns why the instance health should not be set, or "" if it
+// should. The health is not set while the HealthCheck or ReplaceUnhealthy
+// processes of the group are suspended, or while the instance is not in
+// service, for example when it is in a warm pool or on standby. If the group
+// cannot be described the health is set as normal.
+func (a *AutoScaling) skipReason() string {
+	group, instance, err := a.describeGroup()
+	if err != nil {
+		a.logf("Unable to check the AutoScaling group processes: %v", err)
+		return ""
+	}
+	for _, process := range group.SuspendedProcesses {
+		switch aws.StringValue(process.ProcessName) {
+		case "HealthCheck", "ReplaceUnhealthy":
+			return fmt.Sprintf("the %s process of AutoScaling group(%s) is suspended: %s",
+				aws.StringValue(process.ProcessName), aws.StringValue(group.AutoScalingGroupName), aws.StringValue(process.SuspensionReason))
+		}
+	}
+
+	state := aws.StringValue(instance.LifecycleState)
+	if a.TargetLifecycleState != nil {
+		if target, err := a.TargetLifecycleState(); err == nil && target != "" {
+			state = target
+		}
+	}
+	if !inService(state) {
+		return fmt.Sprintf("instance(%s) lifecycle state is %s", a.instanceID, state)
+	}
+	return ""
+}
+
+// inService returns true for the lifecycle states in which the instance is,
+// or is about to be, serving. Warmed:*, Standby, Terminating and Detaching
+// states are not.
+func inService(state string) bool {
+	return state == "" || state == "InService" || strings.HasPrefix(state, "Pending")
+}
+
+func (a *AutoScaling) logf(format string, v ...interface{}) {
+	if a.Logger != nil {
+		a.Logger.Printf(format, v...)
+	}
+}
+
+// GracePeriod returns the HealthCheckGracePeriod of the AutoScaling group
+// the instance is attached to
+func (a *AutoScaling) GracePeriod() (time.Duration, error) {
+	group, _, err := a.describeGroup()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(aws.Int64Value(group.HealthCheckGracePeriod)) * time.Second, nil
+}
+
+// LaunchTime returns the time the instance was launched
+func (a *AutoScaling) LaunchTime() (time.Time, error) {
+	sess, err := a.session()
+	if err != nil {
+		return time.Time{}, err
+	}
+	svc := ec2.New(sess, aws.NewConfig().WithRegion(a.region))
+	output, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{aws.String(a.instanceID)}})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to describe instance(%s): %v", a.instanceID, err)
+	}
+	for _, reservation := range output.Reservations {
+		for _, instance := range reservation.Instances {
+			if instance.LaunchTime != nil {
+				return *instance.LaunchTime, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("no launch time for instance(%s)", a.instanceID)
+}
@@ -0,0 +1,114 @@
+package actions
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAWS serves the query api responses used by AutoScaling, for an instance
+// in the given lifecycle state and a group with the given suspended
+// processes. SetInstanceHealth requests are sent to requests.
+func fakeAWS(t *testing.T, lifecycleState string, suspended string, requests chan<- http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "text/xml")
+		switch r.Form.Get("Action") {
+		case "SetInstanceHealth":
+			if requests != nil {
+				requests <- *r
+			}
+			fmt.Fprint(w, `<SetInstanceHealthResponse><ResponseMetadata><RequestId>1</RequestId></ResponseMetadata></SetInstanceHealthResponse>`)
+		case "DescribeAutoScalingInstances":
+			fmt.Fprintf(w, `<DescribeAutoScalingInstancesResponse><DescribeAutoScalingInstancesResult><AutoScalingInstances><member>
+<InstanceId>i-123</InstanceId><AutoScalingGroupName>web</AutoScalingGroupName><LifecycleState>%s</LifecycleState>
+</member></AutoScalingInstances></DescribeAutoScalingInstancesResult></DescribeAutoScalingInstancesResponse>`, lifecycleState)
+		case "DescribeAutoScalingGroups":
+			fmt.Fprintf(w, `<DescribeAutoScalingGroupsResponse><DescribeAutoScalingGroupsResult><AutoScalingGroups><member>
+<AutoScalingGroupName>web</AutoScalingGroupName><HealthCheckGracePeriod>420</HealthCheckGracePeriod>
+<SuspendedProcesses>%s</SuspendedProcesses>
+</member></AutoScalingGroups></DescribeAutoScalingGroupsResult></DescribeAutoScalingGroupsResponse>`, suspended)
+		case "DescribeInstances":
+			fmt.Fprint(w, `<DescribeInstancesResponse><reservationSet><item><instancesSet><item>
+<instanceId>i-123</instanceId><launchTime>2018-05-01T10:00:00.000Z</launchTime>
+</item></instancesSet></item></reservationSet></DescribeInstancesResponse>`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func testAutoScaling(url string) *AutoScaling {
+	asg := ASGAction("eu-west-1", "i-123", credentials.NewStaticCredentials("id", "secret", ""))
+	asg.endpoint = url
+	return asg
+}
+
+func TestSetInstanceHealth(t *testing.T) {
+	requests := make(chan http.Request, 1)
+	ts := fakeAWS(t, "InService", "", requests)
+	defer ts.Close()
+
+	asg := testAutoScaling(ts.URL)
+	require.NoError(t, asg.SetUnhealthy())
+	r := <-requests
+	assert.Equal(t, "Unhealthy", r.Form.Get("HealthStatus"))
+	assert.Equal(t, "i-123", r.Form.Get("InstanceId"))
+	assert.Equal(t, "", r.Form.Get("ShouldRespectGracePeriod"))
+
+	asg.RespectGracePeriod = true
+	require.NoError(t, asg.SetHealthy())
+	r = <-requests
+	assert.Equal(t, "Healthy", r.Form.Get("HealthStatus"))
+	assert.Equal(t, "true", r.Form.Get("ShouldRespectGracePeriod"))
+}
+
+func TestGracePeriodAndLaunchTime(t *testing.T) {
+	ts := fakeAWS(t, "InService", "", nil)
+	defer ts.Close()
+
+	asg := testAutoScaling(ts.URL)
+	gracePeriod, err := asg.GracePeriod()
+	require.NoError(t, err)
+	assert.Equal(t, 7*time.Minute, gracePeriod)
+
+	launched, err := asg.LaunchTime()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC), launched)
+}
+
+func TestSkippedWhenProcessesSuspended(t *testing.T) {
+	requests := make(chan http.Request, 1)
+	ts := fakeAWS(t, "InService", `<member><ProcessName>ReplaceUnhealthy</ProcessName><SuspensionReason>User suspended</SuspensionReason></member>`, requests)
+	defer ts.Close()
+
+	err := testAutoScaling(ts.URL).SetUnhealthy()
+	assert.True(t, IsSkipped(err))
+	assert.Contains(t, err.Error(), "ReplaceUnhealthy process of AutoScaling group(web) is suspended")
+	assert.Len(t, requests, 0)
+}
+
+func TestSkippedWhenNotInService(t *testing.T) {
+	requests := make(chan http.Request, 1)
+	ts := fakeAWS(t, "Warmed:Running", "", requests)
+	defer ts.Close()
+
+	asg := testAutoScaling(ts.URL)
+	err := asg.SetUnhealthy()
+	assert.True(t, IsSkipped(err))
+	assert.Contains(t, err.Error(), "lifecycle state is Warmed:Running")
+	assert.Len(t, requests, 0)
+
+	// the target lifecycle state from the metadata takes precedence
+	asg.TargetLifecycleState = func() (string, error) {
+		return "InService", nil
+	}
+	require.NoError(t, asg.SetUnhealthy())
+	assert.Len(t, requests, 1)
+}
@@ -0,0 +1,118 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package blackout provides recurring blackout windows, such as a nightly
+// backup, during which either no actions are taken or named checks are
+// ignored. Each window opens on a cron schedule, evaluated in the window's
+// timezone, and stays open for a fixed duration.
+package blackout
+
+import (
+	"sort"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// Window is a recurring blackout window. If Checks is empty no actions are
+// taken during the window, otherwise the named checks are ignored.
+type Window struct {
+	Name     string
+	Schedule cron.Schedule
+	Duration time.Duration
+	Location *time.Location
+	Checks   []string
+}
+
+// Status is the state of a window at a point in time
+type Status struct {
+	Name            string    `json:"name"`
+	Active          bool      `json:"active"`
+	Until           time.Time `json:"until,omitempty"`
+	Next            time.Time `json:"next"`
+	SuppressActions bool      `json:"suppress_actions,omitempty"`
+	Checks          []string  `json:"checks,omitempty"`
+}
+
+// Open returns whether the window is open at t, and when it closes. The window
+// is open if the schedule activated within the last Duration.
+func (w Window) Open(t time.Time) (time.Time, bool) {
+	start := w.Schedule.Next(w.in(t).Add(-w.Duration))
+	if start.After(t) {
+		return time.Time{}, false
+	}
+	return start.Add(w.Duration), true
+}
+
+// SuppressesActions returns whether no actions are taken during the window
+func (w Window) SuppressesActions() bool {
+	return len(w.Checks) == 0
+}
+
+func (w Window) in(t time.Time) time.Time {
+	if w.Location == nil {
+		return t
+	}
+	return t.In(w.Location)
+}
+
+// Windows are the configured blackout windows
+type Windows []Window
+
+// SuppressingActions returns the name of an open window that suppresses
+// actions at t, if there is one
+func (ws Windows) SuppressingActions(t time.Time) (string, bool) {
+	for _, w := range ws {
+		if _, open := w.Open(t); open && w.SuppressesActions() {
+			return w.Name, true
+		}
+	}
+	return "", false
+}
+
+// IgnoredChecks returns the checks ignored at t, mapped to the name of the
+// window ignoring them
+func (ws Windows) IgnoredChecks(t time.Time) map[string]string {
+	ignored := make(map[string]string)
+	for _, w := range ws {
+		if _, open := w.Open(t); open {
+			for _, check := range w.Checks {
+				ignored[check] = w.Name
+			}
+		}
+	}
+	return ignored
+}
+
+// Status returns the state of each window at t, ordered by name
+func (ws Windows) Status(t time.Time) []Status {
+	statuses := make([]Status, 0, len(ws))
+	for _, w := range ws {
+		until, open := w.Open(t)
+		statuses = append(statuses, Status{
+			Name:            w.Name,
+			Active:          open,
+			Until:           until,
+			Next:            w.Schedule.Next(w.in(t)),
+			SuppressActions: w.SuppressesActions(),
+			Checks:          w.Checks,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -0,0 +1,71 @@
+package blackout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func nightlyBackup(t *testing.T, location *time.Location, checks ...string) Window {
+	schedule, err := cron.ParseStandard("0 2 * * *")
+	require.NoError(t, err)
+	return Window{Name: "backup", Schedule: schedule, Duration: 30 * time.Minute, Location: location, Checks: checks}
+}
+
+func TestWindowOpen(t *testing.T) {
+	w := nightlyBackup(t, time.UTC)
+
+	_, open := w.Open(time.Date(2018, 6, 1, 1, 59, 59, 0, time.UTC))
+	assert.False(t, open)
+
+	until, open := w.Open(time.Date(2018, 6, 1, 2, 0, 0, 0, time.UTC))
+	assert.True(t, open)
+	assert.Equal(t, time.Date(2018, 6, 1, 2, 30, 0, 0, time.UTC), until)
+
+	_, open = w.Open(time.Date(2018, 6, 1, 2, 29, 59, 0, time.UTC))
+	assert.True(t, open)
+
+	_, open = w.Open(time.Date(2018, 6, 1, 2, 30, 0, 0, time.UTC))
+	assert.False(t, open)
+}
+
+func TestWindowTimezone(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	require.NoError(t, err)
+	w := nightlyBackup(t, london)
+
+	// 02:00 in London during summer time is 01:00 UTC
+	_, open := w.Open(time.Date(2018, 6, 1, 1, 15, 0, 0, time.UTC))
+	assert.True(t, open)
+	_, open = w.Open(time.Date(2018, 6, 1, 2, 15, 0, 0, time.UTC))
+	assert.False(t, open)
+}
+
+func TestSuppressedActionsAndIgnoredChecks(t *testing.T) {
+	every, err := cron.ParseStandard("0 * * * *")
+	require.NoError(t, err)
+	windows := Windows{
+		nightlyBackup(t, time.UTC, "disk_io", "nginx"),
+		{Name: "hourly", Schedule: every, Duration: 5 * time.Minute, Location: time.UTC},
+	}
+
+	during := time.Date(2018, 6, 1, 2, 10, 0, 0, time.UTC)
+	assert.Equal(t, map[string]string{"disk_io": "backup", "nginx": "backup"}, windows.IgnoredChecks(during))
+	_, suppressed := windows.SuppressingActions(during)
+	assert.False(t, suppressed)
+
+	name, suppressed := windows.SuppressingActions(time.Date(2018, 6, 1, 3, 1, 0, 0, time.UTC))
+	assert.True(t, suppressed)
+	assert.Equal(t, "hourly", name)
+
+	statuses := windows.Status(during)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "backup", statuses[0].Name)
+	assert.True(t, statuses[0].Active)
+	assert.Equal(t, time.Date(2018, 6, 2, 2, 0, 0, 0, time.UTC), statuses[0].Next)
+	assert.False(t, statuses[1].Active)
+	assert.True(t, statuses[1].SuppressActions)
+}
@@ -16,11 +16,18 @@
 package checks
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"net"
 	"net/http"
+	"os/exec"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
+
+	"github.com/tootedom/ec2-local-healthchecker/shell"
 )
 
 // Checker is the interface for a Health Checker
@@ -39,25 +46,113 @@ func (cf CheckFunc) Check() error {
 	return cf()
 }
 
+// ContextChecker is a Checker that stops when its context is done, so that a
+// deadline can be enforced on each run
+type ContextChecker interface {
+	Checker
+	// CheckContext returns nil if the service is okay.
+	CheckContext(ctx context.Context) error
+}
+
+// ContextCheckFunc is a convenience type to create functions that implement
+// the ContextChecker interface
+type ContextCheckFunc func(ctx context.Context) error
+
+// Check implements the Checker interface, without a deadline
+func (cf ContextCheckFunc) Check() error {
+	return cf(context.Background())
+}
+
+// CheckContext implements the ContextChecker interface
+func (cf ContextCheckFunc) CheckContext(ctx context.Context) error {
+	return cf(ctx)
+}
+
+// TimeoutError is the result of a check that did not complete before its
+// deadline
+type TimeoutError struct {
+	Deadline time.Duration
+}
+
+// Error implements the error interface
+func (t TimeoutError) Error() string {
+	return "timed out after " + t.Deadline.String()
+}
+
+// IsTimeout returns true if the error is the result of a check timing out
+func IsTimeout(err error) bool {
+	_, ok := err.(TimeoutError)
+	return ok
+}
+
+// WarningError is returned by a Checker when the service is degraded, but has
+// not failed.
+type WarningError struct {
+	Message string
+}
+
+// Error implements the error interface
+func (w WarningError) Error() string {
+	return w.Message
+}
+
+// Warning returns a WarningError with the given message
+func Warning(message string) error {
+	return WarningError{Message: message}
+}
+
+// IsWarning returns true if the error is a warning rather than a failure
+func IsWarning(err error) bool {
+	_, ok := err.(WarningError)
+	return ok
+}
+
 // HTTPChecker does a GET request and verifies that the HTTP status code
 // returned matches statusCode.
 func HTTPChecker(r string, statusCode int, timeout time.Duration, headers http.Header) Checker {
-	return CheckFunc(func() error {
-		client := http.Client{
-			Timeout: timeout,
-		}
-		req, err := http.NewRequest("GET", r, nil)
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	return httpChecker(client, r, r, statusCode, headers)
+}
+
+// HTTPUnixChecker does a GET request for the path over the unix domain
+// socket, and verifies that the HTTP status code returned matches statusCode.
+// The Host header is localhost, unless set in the headers.
+func HTTPUnixChecker(socket string, path string, statusCode int, timeout time.Duration, headers http.Header) Checker {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	return httpChecker(client, "http://localhost"+path, "unix://"+socket+path, statusCode, headers)
+}
+
+// httpChecker does a GET request for the url with the client, reporting
+// failures for the endpoint
+func httpChecker(client *http.Client, url string, endpoint string, statusCode int, headers http.Header) Checker {
+	return ContextCheckFunc(func(ctx context.Context) error {
+		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
-			return errors.New("error creating request: " + r)
+			return errors.New("error creating request: " + endpoint)
 		}
+		req = req.WithContext(ctx)
 		for headerName, headerValues := range headers {
 			for _, headerValue := range headerValues {
 				req.Header.Add(headerName, headerValue)
 			}
 		}
+		if host := headers.Get("Host"); host != "" {
+			req.Host = host
+		}
 		response, err := client.Do(req)
 		if err != nil {
-			return errors.New("error while checking: " + r)
+			return errors.New("error while checking: " + endpoint)
 		}
 		defer response.Body.Close()
 		if response.StatusCode != statusCode {
@@ -67,10 +162,13 @@ func HTTPChecker(r string, statusCode int, timeout time.Duration, headers http.H
 	})
 }
 
-// TCPChecker attempts to open a TCP connection.
+// TCPChecker attempts to open a TCP connection, or a connection to a unix
+// domain socket if the address is unix:///path/to.sock
 func TCPChecker(addr string, timeout time.Duration) Checker {
-	return CheckFunc(func() error {
-		conn, err := net.DialTimeout("tcp", addr, timeout)
+	return ContextCheckFunc(func(ctx context.Context) error {
+		dialer := net.Dialer{Timeout: timeout}
+		network, address := splitAddress(addr)
+		conn, err := dialer.DialContext(ctx, network, address)
 		if err != nil {
 			return errors.New("connection to " + addr + " failed")
 		}
@@ -78,3 +176,49 @@ func TCPChecker(addr string, timeout time.Duration) Checker {
 		return nil
 	})
 }
+
+// splitAddress returns the network and address to dial for the address,
+// which is either host:port or unix:///path/to.sock
+func splitAddress(addr string) (string, string) {
+	if strings.HasPrefix(addr, "unix://") {
+		return "unix", strings.TrimPrefix(addr, "unix://")
+	}
+	return "tcp", addr
+}
+
+// ExecChecker runs the command through the shell, interpreting the exit code
+// as a Nagios plugin: 0 is OK, 1 is WARNING, 2 is CRITICAL and anything else
+// is UNKNOWN. The first line of output is used as the message. The command,
+// and anything it started, is killed if it has not finished within timeout.
+func ExecChecker(command string, timeout time.Duration) Checker {
+	return ContextCheckFunc(func(ctx context.Context) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		output, err := shell.Command(ctx, command).CombinedOutput()
+		if ctx.Err() == context.DeadlineExceeded {
+			return errors.New("command timed out: " + command)
+		}
+		message := strings.TrimSpace(strings.SplitN(string(bytes.TrimSpace(output)), "\n", 2)[0])
+		if err == nil {
+			return nil
+		}
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return errors.New("unable to run command: " + command + ": " + err.Error())
+		}
+		exitCode := -1
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			exitCode = status.ExitStatus()
+		}
+		switch exitCode {
+		case 1:
+			return Warning("WARNING: " + message)
+		case 2:
+			return errors.New("CRITICAL: " + message)
+		}
+		return errors.New("UNKNOWN(" + strconv.Itoa(exitCode) + "): " + message)
+	})
+}
@@ -2,13 +2,18 @@ package checks
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // This tests GET request with passing in a parameter.
@@ -45,3 +50,87 @@ func TestTCPChecker(t *testing.T) {
 	assert.Equal(t, nil, check.Check())
 
 }
+
+// unixServer serves the handler on a unix domain socket in a temporary
+// directory
+func unixServer(t *testing.T, handler http.Handler) (string, func()) {
+	dir, err := ioutil.TempDir("", "checks")
+	require.NoError(t, err)
+	socket := filepath.Join(dir, "server.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	go http.Serve(listener, handler)
+	return socket, func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestTCPCheckerUnixSocket(t *testing.T) {
+	socket, stop := unixServer(t, http.NotFoundHandler())
+	defer stop()
+
+	assert.NoError(t, TCPChecker("unix://"+socket, time.Second).Check())
+	assert.EqualError(t, TCPChecker("unix://"+socket+".missing", time.Second).Check(),
+		"connection to unix://"+socket+".missing failed")
+}
+
+func TestHTTPUnixChecker(t *testing.T) {
+	socket, stop := unixServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ping" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Host != "localhost" && r.Host != "app.internal" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+		}
+	}))
+	defer stop()
+
+	assert.NoError(t, HTTPUnixChecker(socket, "/_ping", 200, time.Second, nil).Check())
+	assert.NoError(t, HTTPUnixChecker(socket, "/_ping", 200, time.Second, http.Header{"Host": {"app.internal"}}).Check())
+	assert.EqualError(t, HTTPUnixChecker(socket, "/", 200, time.Second, nil).Check(),
+		"downstream service returned unexpected status: 404")
+	assert.EqualError(t, HTTPUnixChecker(socket+".missing", "/_ping", 200, time.Second, nil).Check(),
+		"error while checking: unix://"+socket+".missing/_ping")
+}
+
+func TestHTTPCheckerHostHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "app.internal" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+		}
+	}))
+	defer ts.Close()
+
+	assert.NoError(t, HTTPChecker(ts.URL, 200, time.Second, http.Header{"Host": {"app.internal"}}).Check())
+	assert.EqualError(t, HTTPChecker(ts.URL, 200, time.Second, nil).Check(),
+		"downstream service returned unexpected status: 421")
+}
+
+func TestExecCheckerNagiosExitCodes(t *testing.T) {
+	assert.Nil(t, ExecChecker("echo OK - all good", time.Second*5).Check())
+
+	err := ExecChecker("echo 'WARNING - 85% used'; echo detail; exit 1", time.Second*5).Check()
+	assert.True(t, IsWarning(err))
+	assert.Equal(t, "WARNING: WARNING - 85% used", err.Error())
+
+	err = ExecChecker("echo 'CRITICAL - 99% used'; exit 2", time.Second*5).Check()
+	assert.False(t, IsWarning(err))
+	assert.Equal(t, "CRITICAL: CRITICAL - 99% used", err.Error())
+
+	err = ExecChecker("exit 3", time.Second*5).Check()
+	assert.False(t, IsWarning(err))
+	assert.Contains(t, err.Error(), "UNKNOWN(3)")
+
+	err = ExecChecker("exec sleep 5", time.Millisecond*100).Check()
+	assert.Contains(t, err.Error(), "timed out")
+}
+
+func TestExecCheckerTimeoutKillsChildren(t *testing.T) {
+	// the shell waits for sleep, which holds the output pipe open
+	start := time.Now()
+	err := ExecChecker("sleep 3; true", time.Millisecond*200).Check()
+	assert.EqualError(t, err, "command timed out: sleep 3; true")
+	assert.True(t, time.Since(start) < 2*time.Second, "took %s", time.Since(start))
+}
@@ -0,0 +1,94 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+// DiskThresholds are the limits for a disk check. A zero threshold is not
+// checked.
+type DiskThresholds struct {
+	// MaxUsedPercent is the highest percentage of the filesystem, available
+	// to unprivileged users, that may be used
+	MaxUsedPercent float64
+	// MinFreeBytes is the least space that must be available
+	MinFreeBytes uint64
+	// MinFreeInodes is the least number of inodes that must be free
+	MinFreeInodes uint64
+}
+
+// fileSystemUsage returns the usage of the filesystem mounted at path
+var fileSystemUsage = func(path string) (sigar.FileSystemUsage, error) {
+	usage := sigar.FileSystemUsage{}
+	err := usage.Get(path)
+	return usage, err
+}
+
+// DiskChecker checks the usage of the filesystem mounted at path against the
+// thresholds, reporting the exact usage when it fails
+func DiskChecker(path string, thresholds DiskThresholds) Checker {
+	return CheckFunc(func() error {
+		usage, err := fileSystemUsage(path)
+		if err != nil {
+			return errors.New("unable to get usage of " + path + ": " + err.Error())
+		}
+		// gosigar reports sizes in kilobytes
+		used, avail, total := usage.Used*1024, usage.Avail*1024, usage.Total*1024
+
+		var failures []string
+		if thresholds.MaxUsedPercent > 0 && used+avail > 0 {
+			// as df, the percentage of space available to unprivileged users
+			usedPercent := float64(used) * 100 / float64(used+avail)
+			if usedPercent > thresholds.MaxUsedPercent {
+				failures = append(failures, fmt.Sprintf("%s is %.1f%% used (%s of %s), above %g%%",
+					path, usedPercent, FormatBytes(used), FormatBytes(total), thresholds.MaxUsedPercent))
+			}
+		}
+		if thresholds.MinFreeBytes > 0 && avail < thresholds.MinFreeBytes {
+			failures = append(failures, fmt.Sprintf("%s has %s free, below %s",
+				path, FormatBytes(avail), FormatBytes(thresholds.MinFreeBytes)))
+		}
+		if thresholds.MinFreeInodes > 0 && usage.FreeFiles < thresholds.MinFreeInodes {
+			failures = append(failures, fmt.Sprintf("%s has %d of %d inodes free, below %d",
+				path, usage.FreeFiles, usage.Files, thresholds.MinFreeInodes))
+		}
+		if len(failures) > 0 {
+			return errors.New(strings.Join(failures, "; "))
+		}
+		return nil
+	})
+}
+
+// FormatBytes formats a number of bytes with a binary unit, such as 1.5GiB
+func FormatBytes(bytes uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	return fmt.Sprintf("%.1f%s", size, units[unit])
+}
@@ -0,0 +1,51 @@
+package checks
+
+import (
+	"os"
+	"testing"
+
+	sigar "github.com/cloudfoundry/gosigar"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeFileSystemUsage(usage sigar.FileSystemUsage) func() {
+	previous := fileSystemUsage
+	fileSystemUsage = func(path string) (sigar.FileSystemUsage, error) {
+		return usage, nil
+	}
+	return func() { fileSystemUsage = previous }
+}
+
+func TestDiskChecker(t *testing.T) {
+	// 50GiB filesystem with 46GiB used, 3GiB available and 1000 free inodes
+	defer fakeFileSystemUsage(sigar.FileSystemUsage{
+		Total:     50 * 1024 * 1024,
+		Used:      46 * 1024 * 1024,
+		Free:      4 * 1024 * 1024,
+		Avail:     3 * 1024 * 1024,
+		Files:     3276800,
+		FreeFiles: 1000,
+	})()
+
+	assert.NoError(t, DiskChecker("/data", DiskThresholds{MaxUsedPercent: 95, MinFreeBytes: 1 << 30, MinFreeInodes: 100}).Check())
+
+	assert.EqualError(t, DiskChecker("/data", DiskThresholds{MaxUsedPercent: 90}).Check(),
+		"/data is 93.9% used (46.0GiB of 50.0GiB), above 90%")
+	assert.EqualError(t, DiskChecker("/data", DiskThresholds{MinFreeBytes: 5 << 30}).Check(),
+		"/data has 3.0GiB free, below 5.0GiB")
+	assert.EqualError(t, DiskChecker("/data", DiskThresholds{MinFreeInodes: 10000}).Check(),
+		"/data has 1000 of 3276800 inodes free, below 10000")
+	assert.EqualError(t, DiskChecker("/data", DiskThresholds{MaxUsedPercent: 90, MinFreeInodes: 10000}).Check(),
+		"/data is 93.9% used (46.0GiB of 50.0GiB), above 90%; /data has 1000 of 3276800 inodes free, below 10000")
+}
+
+func TestDiskCheckerOnTempDir(t *testing.T) {
+	assert.NoError(t, DiskChecker(os.TempDir(), DiskThresholds{MaxUsedPercent: 100}).Check())
+	assert.Error(t, DiskChecker("/does/not/exist", DiskThresholds{MaxUsedPercent: 100}).Check())
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512B", FormatBytes(512))
+	assert.Equal(t, "1.5KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0GiB", FormatBytes(2<<30))
+}
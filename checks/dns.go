@@ -0,0 +1,191 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DNSRecordTypes are the record types a DNS check can look up
+var DNSRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SRV", "TXT"}
+
+// DNSChecker resolves the name against the DNS server at server (host:port),
+// and checks that there is an answer of the record type that includes each of
+// the expected values. The name is always resolved as fully qualified.
+func DNSChecker(server string, name string, recordType string, values []string, timeout time.Duration) Checker {
+	recordType = strings.ToUpper(recordType)
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: timeout}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+	return ContextCheckFunc(func(ctx context.Context) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		answers, err := lookup(ctx, resolver, recordType, name)
+		lookupName := recordType + " " + name + " from " + server
+		if err != nil {
+			if dnsErr, ok := err.(*net.DNSError); ok {
+				err = errors.New(dnsErr.Err)
+			}
+			return fmt.Errorf("dns lookup of %s failed: %v", lookupName, err)
+		}
+		if len(answers) == 0 {
+			return fmt.Errorf("dns lookup of %s returned no records", lookupName)
+		}
+		for _, value := range values {
+			if !containsAnswer(answers, value) {
+				return fmt.Errorf("dns lookup of %s returned %v, expected %s", lookupName, answers, value)
+			}
+		}
+		return nil
+	})
+}
+
+// lookup returns the answers of the record type for the name
+func lookup(ctx context.Context, resolver *net.Resolver, recordType string, name string) ([]string, error) {
+	if recordType != "PTR" && !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	var answers []string
+	switch recordType {
+	case "", "A", "AAAA":
+		addrs, err := resolver.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil) == (recordType != "AAAA") {
+				answers = append(answers, addr.IP.String())
+			}
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		// a name with other records but no CNAME is its own canonical name
+		if !strings.EqualFold(cname, name) {
+			answers = append(answers, cname)
+		}
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, mx.Host)
+		}
+	case "NS":
+		nss, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			answers = append(answers, ns.Host)
+		}
+	case "PTR":
+		return resolver.LookupAddr(ctx, name)
+	case "SRV":
+		_, srvs, err := resolver.LookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, err
+		}
+		for _, srv := range srvs {
+			answers = append(answers, net.JoinHostPort(srv.Target, strconv.Itoa(int(srv.Port))))
+		}
+	case "TXT":
+		return resolver.LookupTXT(ctx, name)
+	default:
+		return nil, errors.New("unsupported record type " + recordType)
+	}
+	return answers, nil
+}
+
+// containsAnswer returns whether the value is one of the answers. Names are
+// compared ignoring case and the trailing dot.
+func containsAnswer(answers []string, value string) bool {
+	for _, answer := range answers {
+		if answer == value || strings.EqualFold(strings.TrimSuffix(answer, "."), strings.TrimSuffix(value, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// udpNoResponseWait is how long a UDP check that expects no response waits
+// for an error, such as the port being unreachable
+const udpNoResponseWait = 250 * time.Millisecond
+
+// UDPChecker sends the payload to addr. If a response is expected, it must be
+// received within the timeout. Otherwise, as many UDP services do not reply,
+// the check only fails if the port is unreachable.
+func UDPChecker(addr string, payload []byte, expect Expect, timeout time.Duration) Checker {
+	return ContextCheckFunc(func(ctx context.Context) error {
+		dialer := net.Dialer{Timeout: timeout}
+		conn, err := dialer.DialContext(ctx, "udp", addr)
+		if err != nil {
+			return errors.New("connection to " + addr + " failed")
+		}
+		defer conn.Close()
+
+		wait := timeout
+		if !expect.IsSet() && (wait <= 0 || wait > udpNoResponseWait) {
+			wait = udpNoResponseWait
+		}
+		setDeadline(ctx, conn, wait)
+
+		if _, err := conn.Write(payload); err != nil {
+			return errors.New("unable to send to " + addr + ": " + err.Error())
+		}
+		response := make([]byte, 65536)
+		n, err := conn.Read(response)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				if !expect.IsSet() {
+					return nil
+				}
+				return fmt.Errorf("no response from %s within %s, expected %s", addr, wait, expect)
+			}
+			return errors.New("unable to receive from " + addr + ": " + err.Error())
+		}
+		if expect.IsSet() && !expect.Matches(response[:n]) {
+			return fmt.Errorf("unexpected response from %s: %q, expected %s", addr, truncate(response[:n]), expect)
+		}
+		return nil
+	})
+}
+
+// truncate shortens a response for a failure message
+func truncate(response []byte) string {
+	const max = 128
+	if len(response) > max {
+		return string(response[:max]) + "..."
+	}
+	return string(response)
+}
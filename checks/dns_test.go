@@ -0,0 +1,138 @@
+package checks
+
+import (
+	"encoding/binary"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDNSServer answers A queries for the names in records over UDP, with no
+// answers for other types, and NXDOMAIN for other names
+func fakeDNSServer(t *testing.T, records map[string]net.IP) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			// the question starts after the 12 byte header
+			end := 12
+			var labels []string
+			for query[end] != 0 {
+				length := int(query[end])
+				labels = append(labels, string(query[end+1:end+1+length]))
+				end += length + 1
+			}
+			end += 5
+			qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+
+			response := make([]byte, 12, 512)
+			copy(response, query[:2])
+			ip, ok := records[strings.Join(labels, ".")]
+			flags := uint16(0x8180)
+			if !ok {
+				flags |= 3
+			}
+			binary.BigEndian.PutUint16(response[2:], flags)
+			binary.BigEndian.PutUint16(response[4:], 1)
+			response = append(response, query[12:end]...)
+			if ok && qtype == 1 {
+				binary.BigEndian.PutUint16(response[6:], 1)
+				response = append(response, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				response = append(response, ip.To4()...)
+			}
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func TestDNSChecker(t *testing.T) {
+	server, stop := fakeDNSServer(t, map[string]net.IP{"internal.example.com": net.ParseIP("10.0.0.1")})
+	defer stop()
+
+	assert.NoError(t, DNSChecker(server, "internal.example.com", "A", nil, time.Second).Check())
+	assert.NoError(t, DNSChecker(server, "internal.example.com", "a", []string{"10.0.0.1"}, time.Second).Check())
+	assert.EqualError(t, DNSChecker(server, "internal.example.com", "A", []string{"10.0.0.2"}, time.Second).Check(),
+		"dns lookup of A internal.example.com from "+server+" returned [10.0.0.1], expected 10.0.0.2")
+	assert.EqualError(t, DNSChecker(server, "internal.example.com", "AAAA", nil, time.Second).Check(),
+		"dns lookup of AAAA internal.example.com from "+server+" returned no records")
+	assert.Error(t, DNSChecker(server, "missing.example.com", "A", nil, time.Second).Check())
+	assert.EqualError(t, DNSChecker(server, "internal.example.com", "CNAME", nil, time.Second).Check(),
+		"dns lookup of CNAME internal.example.com from "+server+" returned no records")
+}
+
+func TestDNSCheckerTimesOut(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	assert.Error(t, DNSChecker(conn.LocalAddr().String(), "internal.example.com", "A", nil, 100*time.Millisecond).Check())
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestUDPChecker(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if string(buf[:n]) == "ping\n" {
+				conn.WriteTo([]byte("pong\n"), addr)
+			}
+		}
+	}()
+	addr := conn.LocalAddr().String()
+
+	assert.NoError(t, UDPChecker(addr, []byte("ping\n"), Expect{Literal: []byte("pong")}, time.Second).Check())
+	assert.NoError(t, UDPChecker(addr, []byte("ping\n"), Expect{Pattern: regexp.MustCompile(`^po+ng`)}, time.Second).Check())
+	assert.EqualError(t, UDPChecker(addr, []byte("ping\n"), Expect{Literal: []byte("PONG")}, time.Second).Check(),
+		`unexpected response from `+addr+`: "pong\n", expected "PONG"`)
+	assert.EqualError(t, UDPChecker(addr, []byte("hello"), Expect{Literal: []byte("pong")}, 100*time.Millisecond).Check(),
+		"no response from "+addr+" within 100ms, expected \"pong\"")
+
+	// a daemon such as statsd does not reply
+	assert.NoError(t, UDPChecker(addr, []byte("healthcheck:1|c"), Expect{}, time.Second).Check())
+}
+
+func TestUDPCheckerPortUnreachable(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := conn.LocalAddr().String()
+	conn.Close()
+
+	assert.Error(t, UDPChecker(addr, []byte("healthcheck:1|c"), Expect{}, time.Second).Check())
+}
+
+func TestUnescape(t *testing.T) {
+	for input, expected := range map[string]string{
+		`version\r\n`:  "version\r\n",
+		`\x00\x01ab`:   "\x00\x01ab",
+		`tab\there\\n`: "tab\there\\n",
+		`plain`:        "plain",
+	} {
+		unescaped, err := Unescape(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, string(unescaped), input)
+	}
+	for _, input := range []string{`trailing\`, `\x1`, `\xzz`, `\q`} {
+		_, err := Unescape(input)
+		assert.Error(t, err, input)
+	}
+}
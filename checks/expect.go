@@ -0,0 +1,93 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// Expect is the response expected by a send and expect check: either a
+// literal that the response must contain, or a regular expression it must
+// match. An empty Expect matches any response.
+type Expect struct {
+	Literal []byte
+	Pattern *regexp.Regexp
+}
+
+// IsSet returns true if a response is expected
+func (e Expect) IsSet() bool {
+	return len(e.Literal) > 0 || e.Pattern != nil
+}
+
+// Matches returns true if the response is the one expected
+func (e Expect) Matches(response []byte) bool {
+	if e.Pattern != nil {
+		return e.Pattern.Match(response)
+	}
+	return bytes.Contains(response, e.Literal)
+}
+
+// String describes the expected response
+func (e Expect) String() string {
+	if e.Pattern != nil {
+		return "/" + e.Pattern.String() + "/"
+	}
+	return strconv.Quote(string(e.Literal))
+}
+
+// Unescape replaces the escape sequences \n, \r, \t, \0, \\ and \xHH in s
+func Unescape(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, errors.New("trailing backslash in " + strconv.Quote(s))
+		}
+		switch s[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 'r':
+			buf.WriteByte('\r')
+		case 't':
+			buf.WriteByte('\t')
+		case '0':
+			buf.WriteByte(0)
+		case '\\':
+			buf.WriteByte('\\')
+		case 'x':
+			if i+2 >= len(s) {
+				return nil, errors.New("invalid \\x escape in " + strconv.Quote(s))
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return nil, errors.New("invalid \\x escape in " + strconv.Quote(s))
+			}
+			buf.WriteByte(byte(b))
+			i += 2
+		default:
+			return nil, errors.New("unknown escape \\" + string(s[i]) + " in " + strconv.Quote(s))
+		}
+	}
+	return buf.Bytes(), nil
+}
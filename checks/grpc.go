@@ -0,0 +1,217 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// grpcHealthCheckPath is the method of the standard gRPC health checking
+// protocol
+const grpcHealthCheckPath = "/grpc.health.v1.Health/Check"
+
+// grpcServingStatuses are the names of the HealthCheckResponse statuses
+var grpcServingStatuses = []string{"UNKNOWN", "SERVING", "NOT_SERVING", "SERVICE_UNKNOWN"}
+
+// grpcStatusCodes are the names of the gRPC status codes
+var grpcStatusCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS",
+	"PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// GRPCChecker calls grpc.health.v1.Health/Check on the server at the address,
+// over TLS if tlsConfig is not nil or plaintext HTTP/2 if it is, with the
+// metadata as headers. The service is checked, or the server as a whole if it
+// is empty. Any status other than SERVING is a failure.
+func GRPCChecker(addr string, service string, tlsConfig *tls.Config, metadata map[string]string, timeout time.Duration) Checker {
+	protocols := new(http.Protocols)
+	endpoint := url.URL{Scheme: "https", Host: addr, Path: grpcHealthCheckPath}
+	if tlsConfig == nil {
+		protocols.SetUnencryptedHTTP2(true)
+		endpoint.Scheme = "http"
+	} else {
+		protocols.SetHTTP2(true)
+	}
+	name := "server"
+	if service != "" {
+		name = "service " + strconv.Quote(service)
+	}
+
+	return ContextCheckFunc(func(ctx context.Context) error {
+		transport := &http.Transport{TLSClientConfig: tlsConfig, Protocols: protocols}
+		defer transport.CloseIdleConnections()
+		client := http.Client{Transport: transport, Timeout: timeout}
+
+		req, err := http.NewRequest("POST", endpoint.String(), bytes.NewReader(grpcHealthCheckRequest(service)))
+		if err != nil {
+			return errors.New("error creating request: " + err.Error())
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/grpc")
+		req.Header.Set("TE", "trailers")
+		if timeout > 0 {
+			req.Header.Set("Grpc-Timeout", strconv.FormatInt(int64(timeout/time.Millisecond), 10)+"m")
+		}
+		for key, value := range metadata {
+			req.Header.Set(key, value)
+		}
+
+		response, err := client.Do(req)
+		if err != nil {
+			return errors.New("grpc health check of " + addr + " failed: " + err.Error())
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return errors.New("grpc server at " + addr + " returned unexpected http status: " + strconv.Itoa(response.StatusCode))
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxExpectResponse))
+		if err != nil {
+			return errors.New("unable to read grpc health check response from " + addr + ": " + err.Error())
+		}
+
+		// a response without a message has the status in its headers
+		status := response.Trailer.Get("Grpc-Status")
+		message := response.Trailer.Get("Grpc-Message")
+		if status == "" {
+			status, message = response.Header.Get("Grpc-Status"), response.Header.Get("Grpc-Message")
+		}
+		if status != "0" {
+			return grpcStatusError(name, status, message)
+		}
+
+		servingStatus, err := grpcServingStatus(body)
+		if err != nil {
+			return errors.New("invalid grpc health check response from " + addr + ": " + err.Error())
+		}
+		if servingStatus != 1 {
+			return fmt.Errorf("%s is %s", name, grpcServingStatusName(servingStatus))
+		}
+		return nil
+	})
+}
+
+// grpcStatusError returns the error of a failed call, with the name of its
+// status code
+func grpcStatusError(name string, status string, message string) error {
+	if status == "" {
+		return errors.New("grpc health check of " + name + " returned no status")
+	}
+	if code, err := strconv.Atoi(status); err == nil && code >= 0 && code < len(grpcStatusCodes) {
+		status = grpcStatusCodes[code]
+	}
+	if message, err := url.PathUnescape(message); err == nil && message != "" {
+		return errors.New("grpc health check of " + name + " failed: " + status + ": " + message)
+	}
+	return errors.New("grpc health check of " + name + " failed: " + status)
+}
+
+// grpcHealthCheckRequest returns the length prefixed HealthCheckRequest
+// message for the service
+func grpcHealthCheckRequest(service string) []byte {
+	var message []byte
+	if service != "" {
+		message = append(message, 0x0a)
+		message = appendVarint(message, uint64(len(service)))
+		message = append(message, service...)
+	}
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcServingStatus returns the status of the length prefixed
+// HealthCheckResponse message
+func grpcServingStatus(body []byte) (uint64, error) {
+	if len(body) < 5 {
+		return 0, errors.New("no message")
+	}
+	if body[0] != 0 {
+		return 0, errors.New("compressed message")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint32(len(body)-5) < length {
+		return 0, errors.New("truncated message")
+	}
+	message := body[5 : 5+length]
+
+	var status uint64
+	for len(message) > 0 {
+		key, n := binary.Uvarint(message)
+		if n <= 0 {
+			return 0, errors.New("invalid field")
+		}
+		message = message[n:]
+		switch key & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return 0, errors.New("invalid field")
+			}
+			message = message[n:]
+			if key>>3 == 1 {
+				status = value
+			}
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return 0, errors.New("invalid field")
+			}
+			message = message[n+int(length):]
+		case 1, 5:
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(message) < size {
+				return 0, errors.New("invalid field")
+			}
+			message = message[size:]
+		default:
+			return 0, errors.New("invalid field")
+		}
+	}
+	return status, nil
+}
+
+// grpcServingStatusName returns the name of a HealthCheckResponse status
+func grpcServingStatusName(status uint64) string {
+	if status < uint64(len(grpcServingStatuses)) {
+		return grpcServingStatuses[status]
+	}
+	return "status " + strconv.FormatUint(status, 10)
+}
+
+// appendVarint appends the protobuf varint encoding of the value
+func appendVarint(b []byte, value uint64) []byte {
+	for value >= 0x80 {
+		b = append(b, byte(value)|0x80)
+		value >>= 7
+	}
+	return append(b, byte(value))
+}
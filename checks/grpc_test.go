@@ -0,0 +1,135 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grpcHealthHandler serves grpc.health.v1.Health/Check with the statuses of
+// the services, requiring the authorization metadata if it is set
+func grpcHealthHandler(t *testing.T, statuses map[string]uint64, authorization string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "HTTP/2.0", r.Proto)
+		assert.Equal(t, grpcHealthCheckPath, r.URL.Path)
+		assert.Equal(t, "application/grpc", r.Header.Get("Content-Type"))
+		w.Header().Set("Content-Type", "application/grpc")
+		if authorization != "" && r.Header.Get("Authorization") != authorization {
+			w.Header().Set("Grpc-Status", "16")
+			w.Header().Set("Grpc-Message", "invalid%20token")
+			return
+		}
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var service string
+		if len(body) > 7 {
+			service = string(body[7:])
+		}
+		status, ok := statuses[service]
+		if !ok {
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "unknown service")
+			return
+		}
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		message := []byte{0x08, byte(status)}
+		frame := make([]byte, 5)
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+		w.Write(append(frame, message...))
+		w.Header().Set("Grpc-Status", "0")
+	}
+}
+
+func TestGRPCCheckerPlaintext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := &http.Server{
+		Handler:   grpcHealthHandler(t, map[string]uint64{"": 1, "orders": 1, "payments": 2}, "Bearer token"),
+		Protocols: new(http.Protocols),
+	}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	go server.Serve(listener)
+	defer server.Close()
+	addr := listener.Addr().String()
+	metadata := map[string]string{"authorization": "Bearer token"}
+
+	assert.NoError(t, GRPCChecker(addr, "", nil, metadata, time.Second).Check())
+	assert.NoError(t, GRPCChecker(addr, "orders", nil, metadata, time.Second).Check())
+	assert.EqualError(t, GRPCChecker(addr, "payments", nil, metadata, time.Second).Check(),
+		`service "payments" is NOT_SERVING`)
+	assert.EqualError(t, GRPCChecker(addr, "stock", nil, metadata, time.Second).Check(),
+		`grpc health check of service "stock" failed: NOT_FOUND: unknown service`)
+	assert.EqualError(t, GRPCChecker(addr, "", nil, nil, time.Second).Check(),
+		"grpc health check of server failed: UNAUTHENTICATED: invalid token")
+}
+
+func TestGRPCCheckerTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(grpcHealthHandler(t, map[string]uint64{"": 3}, ""))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	assert.EqualError(t, GRPCChecker(addr, "", tlsConfig, nil, time.Second).Check(), "server is SERVICE_UNKNOWN")
+
+	err := GRPCChecker(addr, "", &tls.Config{}, nil, time.Second).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "grpc health check of "+addr+" failed: ")
+	}
+}
+
+func TestGRPCCheckerTimeout(t *testing.T) {
+	addr, stop := lineServer(t, func(line string) string { return "" })
+	defer stop()
+
+	err := GRPCChecker(addr, "", nil, nil, 50*time.Millisecond).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "grpc health check of "+addr+" failed: ")
+	}
+}
+
+func TestGRPCHealthCheckMessages(t *testing.T) {
+	assert.Equal(t, []byte{0, 0, 0, 0, 0}, grpcHealthCheckRequest(""))
+	assert.Equal(t, []byte{0, 0, 0, 0, 8, 0x0a, 6, 'o', 'r', 'd', 'e', 'r', 's'}, grpcHealthCheckRequest("orders"))
+
+	status, err := grpcServingStatus([]byte{0, 0, 0, 0, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, "UNKNOWN", grpcServingStatusName(status))
+	// unknown fields are skipped
+	status, err = grpcServingStatus([]byte{0, 0, 0, 0, 6, 0x12, 2, 'o', 'k', 0x08, 1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), status)
+	assert.Equal(t, "status 7", grpcServingStatusName(7))
+
+	_, err = grpcServingStatus([]byte{0, 0, 0, 0, 4, 0x08})
+	assert.EqualError(t, err, "truncated message")
+	_, err = grpcServingStatus([]byte{1, 0, 0, 0, 0})
+	assert.EqualError(t, err, "compressed message")
+}
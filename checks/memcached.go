@@ -0,0 +1,222 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemcachedOptions configure a memcached check
+type MemcachedOptions struct {
+	// MaxEvictions is the most items that may be evicted between checks. A
+	// negative threshold is not checked.
+	MaxEvictions int64
+	// MaxConnectionsPercent is the highest percentage of the connection
+	// limit that may be in use. A zero threshold is not checked.
+	MaxConnectionsPercent float64
+	// CanaryKey, if set, is set and read back on each check
+	CanaryKey string
+}
+
+// canaryExpiry is the expiry, in seconds, of the canary key
+const canaryExpiry = 60
+
+// memcachedChecker keeps the evictions seen by the previous check
+type memcachedChecker struct {
+	addr      string
+	tlsConfig *tls.Config
+	options   MemcachedOptions
+	timeout   time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	evictions uint64
+	pid       string
+	seen      bool
+}
+
+// MemcachedChecker checks that memcached responds to stats, and optionally
+// can store and return the canary key, within the timeout. The stats are
+// checked against the thresholds.
+func MemcachedChecker(addr string, tlsConfig *tls.Config, options MemcachedOptions, timeout time.Duration) Checker {
+	checker := &memcachedChecker{addr: addr, tlsConfig: tlsConfig, options: options, timeout: timeout, now: time.Now}
+	return ContextCheckFunc(checker.check)
+}
+
+func (m *memcachedChecker) check(ctx context.Context) error {
+	conn, err := dial(ctx, m.addr, m.tlsConfig, m.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	stats, err := memcachedStats(conn, r, "stats")
+	if err != nil {
+		return m.failed(err)
+	}
+	if _, ok := stats["max_connections"]; !ok && m.options.MaxConnectionsPercent > 0 {
+		settings, err := memcachedStats(conn, r, "stats settings")
+		if err != nil {
+			return m.failed(err)
+		}
+		stats["max_connections"] = settings["maxconns"]
+	}
+
+	var failures []string
+	if failure := m.checkEvictions(stats); failure != "" {
+		failures = append(failures, failure)
+	}
+	if m.options.MaxConnectionsPercent > 0 {
+		current, _ := strconv.ParseFloat(stats["curr_connections"], 64)
+		limit, _ := strconv.ParseFloat(stats["max_connections"], 64)
+		if limit > 0 && current*100/limit > m.options.MaxConnectionsPercent {
+			failures = append(failures, fmt.Sprintf("%.0f of %.0f connections in use (%.1f%%), above %g%%",
+				current, limit, current*100/limit, m.options.MaxConnectionsPercent))
+		}
+	}
+	if m.options.CanaryKey != "" {
+		if err := m.canary(conn, r); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// failed returns the error of a failed exchange with memcached
+func (m *memcachedChecker) failed(err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return errors.New("no response from memcached at " + m.addr + " before the deadline")
+	}
+	return errors.New("memcached at " + m.addr + " failed: " + err.Error())
+}
+
+// checkEvictions returns a failure if more items were evicted since the
+// previous check than allowed. A restart of memcached, seen by a change of
+// pid, resets the count.
+func (m *memcachedChecker) checkEvictions(stats map[string]string) string {
+	if m.options.MaxEvictions < 0 {
+		return ""
+	}
+	evictions, err := strconv.ParseUint(stats["evictions"], 10, 64)
+	if err != nil {
+		return ""
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous, seen := m.evictions, m.seen && m.pid == stats["pid"] && evictions >= m.evictions
+	m.evictions, m.pid, m.seen = evictions, stats["pid"], true
+	if seen && evictions-previous > uint64(m.options.MaxEvictions) {
+		return fmt.Sprintf("%d items evicted since the last check, above %d", evictions-previous, m.options.MaxEvictions)
+	}
+	return ""
+}
+
+// canary sets the canary key to the current time, and checks the same value
+// is returned by a get
+func (m *memcachedChecker) canary(conn net.Conn, r *bufio.Reader) error {
+	key := m.options.CanaryKey
+	value := strconv.FormatInt(m.now().UnixNano(), 10)
+	if _, err := fmt.Fprintf(conn, "set %s 0 %d %d\r\n%s\r\n", key, canaryExpiry, len(value), value); err != nil {
+		return m.failed(err)
+	}
+	line, err := memcachedLine(r)
+	if err != nil {
+		return m.failed(err)
+	}
+	if line != "STORED" {
+		return errors.New("unable to set canary " + key + ": " + line)
+	}
+
+	if _, err := fmt.Fprintf(conn, "get %s\r\n", key); err != nil {
+		return m.failed(err)
+	}
+	var got string
+	for {
+		line, err := memcachedLine(r)
+		if err != nil {
+			return m.failed(err)
+		}
+		if line == "END" {
+			break
+		}
+		if !strings.HasPrefix(line, "VALUE ") {
+			return errors.New("unable to get canary " + key + ": " + line)
+		}
+		data, err := memcachedLine(r)
+		if err != nil {
+			return m.failed(err)
+		}
+		got = data
+	}
+	if got != value {
+		return fmt.Errorf("canary %s returned %q, expected %q", key, got, value)
+	}
+	return nil
+}
+
+// memcachedStats sends the stats command and returns the stats
+func memcachedStats(conn net.Conn, r *bufio.Reader, command string) (map[string]string, error) {
+	if _, err := conn.Write([]byte(command + "\r\n")); err != nil {
+		return nil, err
+	}
+	stats := make(map[string]string)
+	for {
+		line, err := memcachedLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if line == "END" {
+			return stats, nil
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "STAT" {
+			return nil, errors.New(command + " returned " + line)
+		}
+		if len(fields) > 2 {
+			stats[fields[1]] = fields[2]
+		} else {
+			stats[fields[1]] = ""
+		}
+	}
+}
+
+// memcachedLine reads a line of a response, returning an error for an error
+// response
+func memcachedLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "ERROR" || strings.HasPrefix(line, "SERVER_ERROR") || strings.HasPrefix(line, "CLIENT_ERROR") {
+		return "", errors.New(line)
+	}
+	return line, nil
+}
@@ -0,0 +1,156 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMemcached serves stats, stats settings, set and get
+type fakeMemcached struct {
+	mu        sync.Mutex
+	stats     map[string]string
+	settings  map[string]string
+	items     map[string]string
+	setting   string
+	setResult string
+}
+
+func (f *fakeMemcached) reply(line string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.setting != "" {
+		f.items[f.setting], f.setting = line, ""
+		return f.setResult + "\r\n"
+	}
+	fields := strings.Fields(line)
+	switch {
+	case line == "stats" || line == "stats settings":
+		stats := f.stats
+		if line == "stats settings" {
+			stats = f.settings
+		}
+		response := ""
+		for name, value := range stats {
+			response += "STAT " + name + " " + value + "\r\n"
+		}
+		return response + "END\r\n"
+	case len(fields) == 5 && fields[0] == "set":
+		f.setting = fields[1]
+		return ""
+	case len(fields) == 2 && fields[0] == "get":
+		value, ok := f.items[fields[1]]
+		if !ok {
+			return "END\r\n"
+		}
+		return "VALUE " + fields[1] + " 0 " + strconv.Itoa(len(value)) + "\r\n" + value + "\r\nEND\r\n"
+	}
+	return "ERROR\r\n"
+}
+
+func TestMemcachedChecker(t *testing.T) {
+	fake := &fakeMemcached{
+		stats:     map[string]string{"pid": "42", "evictions": "10", "curr_connections": "10", "max_connections": "1024"},
+		settings:  map[string]string{"maxconns": "100"},
+		items:     make(map[string]string),
+		setResult: "STORED",
+	}
+	addr, stop := lineServer(t, fake.reply)
+	defer stop()
+
+	checker := MemcachedChecker(addr, nil, MemcachedOptions{MaxEvictions: 5, MaxConnectionsPercent: 50, CanaryKey: "healthcheck"}, time.Second)
+	assert.NoError(t, checker.Check())
+	assert.Contains(t, fake.items, "healthcheck")
+
+	fake.mu.Lock()
+	fake.stats["evictions"] = "20"
+	fake.stats["curr_connections"] = "600"
+	fake.setResult = "SERVER_ERROR out of memory storing object"
+	fake.mu.Unlock()
+	assert.EqualError(t, checker.Check(), "10 items evicted since the last check, above 5; "+
+		"600 of 1024 connections in use (58.6%), above 50%; "+
+		"memcached at "+addr+" failed: SERVER_ERROR out of memory storing object")
+
+	fake.mu.Lock()
+	fake.stats["pid"] = "43"
+	fake.stats["evictions"] = "30"
+	fake.mu.Unlock()
+	checker = MemcachedChecker(addr, nil, MemcachedOptions{MaxEvictions: 5}, time.Second)
+	assert.NoError(t, checker.Check())
+}
+
+func TestMemcachedCheckerEvictionsResetOnRestart(t *testing.T) {
+	fake := &fakeMemcached{stats: map[string]string{"pid": "42", "evictions": "100"}}
+	addr, stop := lineServer(t, fake.reply)
+	defer stop()
+
+	checker := MemcachedChecker(addr, nil, MemcachedOptions{MaxEvictions: 0}, time.Second)
+	assert.NoError(t, checker.Check())
+
+	fake.mu.Lock()
+	fake.stats["pid"], fake.stats["evictions"] = "43", "3"
+	fake.mu.Unlock()
+	assert.NoError(t, checker.Check())
+
+	fake.mu.Lock()
+	fake.stats["evictions"] = "4"
+	fake.mu.Unlock()
+	assert.EqualError(t, checker.Check(), "1 items evicted since the last check, above 0")
+}
+
+func TestMemcachedCheckerConnectionsFromSettings(t *testing.T) {
+	fake := &fakeMemcached{
+		stats:    map[string]string{"curr_connections": "90"},
+		settings: map[string]string{"maxconns": "100"},
+	}
+	addr, stop := lineServer(t, fake.reply)
+	defer stop()
+
+	checker := MemcachedChecker(addr, nil, MemcachedOptions{MaxEvictions: -1, MaxConnectionsPercent: 80}, time.Second)
+	assert.EqualError(t, checker.Check(), "90 of 100 connections in use (90.0%), above 80%")
+}
+
+func TestMemcachedCheckerCanaryMismatch(t *testing.T) {
+	fake := &fakeMemcached{stats: map[string]string{}, items: make(map[string]string), setResult: "STORED"}
+	addr, stop := lineServer(t, func(line string) string {
+		if strings.HasPrefix(line, "get ") {
+			return "END\r\n"
+		}
+		return fake.reply(line)
+	})
+	defer stop()
+
+	checker := MemcachedChecker(addr, nil, MemcachedOptions{MaxEvictions: -1, CanaryKey: "healthcheck"}, time.Second)
+	err := checker.Check()
+	if assert.Error(t, err) {
+		assert.Regexp(t, `^canary healthcheck returned "", expected "[0-9]+"$`, err.Error())
+	}
+}
+
+func TestMemcachedCheckerTimeout(t *testing.T) {
+	addr, stop := lineServer(t, func(line string) string { return "" })
+	defer stop()
+
+	checker := MemcachedChecker(addr, nil, MemcachedOptions{}, 50*time.Millisecond)
+	assert.EqualError(t, checker.Check(), "no response from memcached at "+addr+" before the deadline")
+}
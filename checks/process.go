@@ -0,0 +1,171 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+// ProcessMatch selects the processes for a process check, by the pid in a pid
+// file, the exact process name, or a regular expression matched against the
+// command line. When a pid file is given, the name and command line, if set,
+// must also match the process, so that a reused pid is not mistaken for the
+// service.
+type ProcessMatch struct {
+	PidFile string
+	Name    string
+	Cmdline *regexp.Regexp
+}
+
+// String describes the match
+func (m ProcessMatch) String() string {
+	var parts []string
+	if m.PidFile != "" {
+		parts = append(parts, "pid file "+m.PidFile)
+	}
+	if m.Name != "" {
+		parts = append(parts, "name "+strconv.Quote(m.Name))
+	}
+	if m.Cmdline != nil {
+		parts = append(parts, "command line "+strconv.Quote(m.Cmdline.String()))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// listProcesses, processState and processArgs read the process table
+var listProcesses = func() ([]int, error) {
+	list := sigar.ProcList{}
+	err := list.Get()
+	return list.List, err
+}
+
+var processState = func(pid int) (sigar.ProcState, error) {
+	state := sigar.ProcState{}
+	err := state.Get(pid)
+	return state, err
+}
+
+var processArgs = func(pid int) ([]string, error) {
+	args := sigar.ProcArgs{}
+	err := args.Get(pid)
+	return args.List, err
+}
+
+// Pids returns the sorted pids of the running processes that match. The
+// checker's own process is never matched.
+func (m ProcessMatch) Pids() ([]int, error) {
+	var candidates []int
+	if m.PidFile != "" {
+		content, err := ioutil.ReadFile(m.PidFile)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			return nil, errors.New("invalid pid file " + m.PidFile + ": " + err.Error())
+		}
+		candidates = []int{pid}
+	} else {
+		var err error
+		if candidates, err = listProcesses(); err != nil {
+			return nil, err
+		}
+	}
+
+	pids := []int{}
+	for _, pid := range candidates {
+		if pid != os.Getpid() && m.matches(pid) {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+	return pids, nil
+}
+
+// matches returns whether the process is running, and has the name and
+// command line of the match. Processes that have exited are skipped.
+func (m ProcessMatch) matches(pid int) bool {
+	state, err := processState(pid)
+	if err != nil || state.State == sigar.RunStateZombie {
+		return false
+	}
+	if m.Name != "" && state.Name != m.Name {
+		return false
+	}
+	if m.Cmdline != nil {
+		args, err := processArgs(pid)
+		if err != nil || !m.Cmdline.MatchString(strings.Join(args, " ")) {
+			return false
+		}
+	}
+	return true
+}
+
+// ProcessChecker checks that between min and max processes match; a max of
+// zero is unlimited. If failOnRestart is set, the check also fails when a
+// process that matched on the previous run is no longer running, such as when
+// a service is in a crash and restart loop.
+func ProcessChecker(match ProcessMatch, min int, max int, failOnRestart bool) Checker {
+	var mu sync.Mutex
+	var previous []int
+	return CheckFunc(func() error {
+		pids, err := match.Pids()
+		if err != nil {
+			return errors.New("unable to find processes matching " + match.String() + ": " + err.Error())
+		}
+		if len(pids) < min {
+			return fmt.Errorf("found %d processes matching %s, expected at least %d", len(pids), match, min)
+		}
+		if max > 0 && len(pids) > max {
+			return fmt.Errorf("found %d processes matching %s, expected at most %d: %v", len(pids), match, max, pids)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		gone := missing(previous, pids)
+		previous = pids
+		if failOnRestart && len(gone) > 0 {
+			return fmt.Errorf("processes %v matching %s are no longer running, now %v", gone, match, pids)
+		}
+		return nil
+	})
+}
+
+// missing returns the pids in previous that are not in current
+func missing(previous []int, current []int) []int {
+	var gone []int
+	for _, pid := range previous {
+		i := sort.SearchInts(current, pid)
+		if i == len(current) || current[i] != pid {
+			gone = append(gone, pid)
+		}
+	}
+	return gone
+}
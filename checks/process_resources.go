@@ -0,0 +1,237 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+// ProcessResourceThresholds are the limits for a process resource check. A
+// zero threshold is not checked.
+type ProcessResourceThresholds struct {
+	// MaxRSSBytes is the largest resident set size
+	MaxRSSBytes uint64
+	// MaxRSSPercent is the largest resident set size, as a percentage of the
+	// memory of the instance
+	MaxRSSPercent float64
+	// MaxCPUPercent is the most CPU used between runs, as a percentage of one
+	// CPU
+	MaxCPUPercent float64
+	// MaxFDs is the most open file descriptors
+	MaxFDs uint64
+	// MaxFDsPercent is the most open file descriptors, as a percentage of the
+	// soft limit on open files
+	MaxFDsPercent float64
+	// MaxThreads is the most threads
+	MaxThreads uint64
+	// MaxThreadsPercent is the most threads, as a percentage of the soft
+	// limit on processes, which threads count against
+	MaxThreadsPercent float64
+}
+
+// ProcessResources is the resource usage of a process
+type ProcessResources struct {
+	RSS         uint64
+	CPUMillis   uint64
+	FDs         uint64
+	FDLimit     uint64
+	Threads     uint64
+	ThreadLimit uint64
+}
+
+const procfs = "/proc"
+
+// readProcessResources reads the resource usage of the process. The RSS and
+// CPU time come from gosigar; the open file descriptors, limits and threads
+// are read from procfs, as gosigar's ProcState has no thread count.
+var readProcessResources = func(pid int) (ProcessResources, error) {
+	resources := ProcessResources{}
+	mem, cpu := sigar.ProcMem{}, sigar.ProcTime{}
+	if err := mem.Get(pid); err != nil {
+		return resources, err
+	}
+	if err := cpu.Get(pid); err != nil {
+		return resources, err
+	}
+	resources.RSS, resources.CPUMillis = mem.Resident, cpu.Total
+
+	dir := procfs + "/" + strconv.Itoa(pid)
+	fds, err := ioutil.ReadDir(dir + "/fd")
+	if err != nil {
+		return resources, err
+	}
+	resources.FDs = uint64(len(fds))
+
+	limits, err := ioutil.ReadFile(dir + "/limits")
+	if err != nil {
+		return resources, err
+	}
+	resources.FDLimit = softLimit(limits, "Max open files")
+	resources.ThreadLimit = softLimit(limits, "Max processes")
+
+	status, err := ioutil.ReadFile(dir + "/status")
+	if err != nil {
+		return resources, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(status))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) == 2 && fields[0] == "Threads:" {
+			resources.Threads, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return resources, nil
+}
+
+// softLimit returns the soft limit from the content of /proc/<pid>/limits, or
+// zero if it is unlimited
+func softLimit(limits []byte, name string) uint64 {
+	scanner := bufio.NewScanner(bytes.NewReader(limits))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, name+" ") {
+			fields := strings.Fields(strings.TrimPrefix(line, name))
+			if len(fields) > 0 {
+				limit, _ := strconv.ParseUint(fields[0], 10, 64)
+				return limit
+			}
+		}
+	}
+	return 0
+}
+
+// totalMemory returns the memory of the instance
+var totalMemory = func() (uint64, error) {
+	mem := sigar.Mem{}
+	err := mem.Get()
+	return mem.Total, err
+}
+
+type cpuSample struct {
+	millis uint64
+	at     time.Time
+}
+
+type processResourceChecker struct {
+	match      ProcessMatch
+	thresholds ProcessResourceThresholds
+	now        func() time.Time
+
+	mu      sync.Mutex
+	samples map[int]cpuSample
+}
+
+// ProcessResourceChecker checks the resource usage of each matching process
+// against the thresholds. The CPU percentage is measured between runs, so it is
+// not checked on the first run.
+func ProcessResourceChecker(match ProcessMatch, thresholds ProcessResourceThresholds) Checker {
+	return &processResourceChecker{match: match, thresholds: thresholds, now: time.Now, samples: make(map[int]cpuSample)}
+}
+
+// Check implements the Checker interface
+func (pc *processResourceChecker) Check() error {
+	pids, err := pc.match.Pids()
+	if err != nil {
+		return errors.New("unable to find processes matching " + pc.match.String() + ": " + err.Error())
+	}
+	if len(pids) == 0 {
+		return errors.New("no processes matching " + pc.match.String())
+	}
+
+	var memory uint64
+	if pc.thresholds.MaxRSSPercent > 0 {
+		if memory, err = totalMemory(); err != nil {
+			return errors.New("unable to get memory: " + err.Error())
+		}
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	samples := make(map[int]cpuSample)
+	var failures []string
+	for _, pid := range pids {
+		resources, err := readProcessResources(pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// the process exited after it was matched
+				continue
+			}
+			failures = append(failures, fmt.Sprintf("unable to read resources of process %d: %v", pid, err))
+			continue
+		}
+		sample := cpuSample{millis: resources.CPUMillis, at: pc.now()}
+		samples[pid] = sample
+		failures = append(failures, pc.exceeded(pid, resources, memory, sample)...)
+	}
+	pc.samples = samples
+
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// exceeded returns a message for each threshold the process exceeds
+func (pc *processResourceChecker) exceeded(pid int, resources ProcessResources, memory uint64, sample cpuSample) []string {
+	t := pc.thresholds
+	var failures []string
+	if t.MaxRSSBytes > 0 && resources.RSS > t.MaxRSSBytes {
+		failures = append(failures, fmt.Sprintf("process %d rss is %s, above %s", pid, FormatBytes(resources.RSS), FormatBytes(t.MaxRSSBytes)))
+	}
+	if t.MaxRSSPercent > 0 && memory > 0 {
+		if percent := float64(resources.RSS) * 100 / float64(memory); percent > t.MaxRSSPercent {
+			failures = append(failures, fmt.Sprintf("process %d rss is %s (%.1f%% of %s), above %g%%",
+				pid, FormatBytes(resources.RSS), percent, FormatBytes(memory), t.MaxRSSPercent))
+		}
+	}
+	if previous, ok := pc.samples[pid]; ok && t.MaxCPUPercent > 0 && sample.at.After(previous.at) && sample.millis >= previous.millis {
+		elapsed := sample.at.Sub(previous.at)
+		percent := float64(sample.millis-previous.millis) * 100 / (float64(elapsed) / float64(time.Millisecond))
+		if percent > t.MaxCPUPercent {
+			failures = append(failures, fmt.Sprintf("process %d used %.1f%% cpu over %s, above %g%%", pid, percent, elapsed, t.MaxCPUPercent))
+		}
+	}
+	if t.MaxFDs > 0 && resources.FDs > t.MaxFDs {
+		failures = append(failures, fmt.Sprintf("process %d has %d open files, above %d", pid, resources.FDs, t.MaxFDs))
+	}
+	if t.MaxFDsPercent > 0 && resources.FDLimit > 0 {
+		if percent := float64(resources.FDs) * 100 / float64(resources.FDLimit); percent > t.MaxFDsPercent {
+			failures = append(failures, fmt.Sprintf("process %d has %d open files (%.1f%% of the limit of %d), above %g%%",
+				pid, resources.FDs, percent, resources.FDLimit, t.MaxFDsPercent))
+		}
+	}
+	if t.MaxThreads > 0 && resources.Threads > t.MaxThreads {
+		failures = append(failures, fmt.Sprintf("process %d has %d threads, above %d", pid, resources.Threads, t.MaxThreads))
+	}
+	if t.MaxThreadsPercent > 0 && resources.ThreadLimit > 0 {
+		if percent := float64(resources.Threads) * 100 / float64(resources.ThreadLimit); percent > t.MaxThreadsPercent {
+			failures = append(failures, fmt.Sprintf("process %d has %d threads (%.1f%% of the limit of %d), above %g%%",
+				pid, resources.Threads, percent, resources.ThreadLimit, t.MaxThreadsPercent))
+		}
+	}
+	return failures
+}
@@ -0,0 +1,83 @@
+package checks
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeProcessResources(resources map[int]ProcessResources, memory uint64) func() {
+	previousResources, previousMemory := readProcessResources, totalMemory
+	readProcessResources = func(pid int) (ProcessResources, error) {
+		return resources[pid], nil
+	}
+	totalMemory = func() (uint64, error) {
+		return memory, nil
+	}
+	return func() {
+		readProcessResources, totalMemory = previousResources, previousMemory
+	}
+}
+
+func TestProcessResourceChecker(t *testing.T) {
+	defer fakeProcesses(map[int]fakeProcess{400: {name: "java"}})()
+	defer fakeProcessResources(map[int]ProcessResources{
+		400: {RSS: 6 << 30, FDs: 3500, FDLimit: 4096, Threads: 900, ThreadLimit: 1024},
+	}, 8<<30)()
+	match := ProcessMatch{Name: "java"}
+
+	assert.NoError(t, ProcessResourceChecker(match, ProcessResourceThresholds{MaxRSSBytes: 7 << 30, MaxFDs: 4000, MaxThreads: 1000}).Check())
+
+	for thresholds, expected := range map[ProcessResourceThresholds]string{
+		{MaxRSSBytes: 4 << 30}:                  "process 400 rss is 6.0GiB, above 4.0GiB",
+		{MaxRSSPercent: 70}:                     "process 400 rss is 6.0GiB (75.0% of 8.0GiB), above 70%",
+		{MaxFDs: 3000}:                          "process 400 has 3500 open files, above 3000",
+		{MaxFDsPercent: 80}:                     "process 400 has 3500 open files (85.4% of the limit of 4096), above 80%",
+		{MaxThreads: 500}:                       "process 400 has 900 threads, above 500",
+		{MaxThreadsPercent: 80}:                 "process 400 has 900 threads (87.9% of the limit of 1024), above 80%",
+		{MaxRSSBytes: 4 << 30, MaxThreads: 500}: "process 400 rss is 6.0GiB, above 4.0GiB; process 400 has 900 threads, above 500",
+	} {
+		assert.EqualError(t, ProcessResourceChecker(match, thresholds).Check(), expected)
+	}
+
+	assert.EqualError(t, ProcessResourceChecker(ProcessMatch{Name: "nginx"}, ProcessResourceThresholds{MaxFDs: 1}).Check(),
+		`no processes matching name "nginx"`)
+}
+
+func TestProcessResourceCheckerCPU(t *testing.T) {
+	resources := map[int]ProcessResources{500: {CPUMillis: 1000}}
+	defer fakeProcesses(map[int]fakeProcess{500: {name: "worker"}})()
+	defer fakeProcessResources(resources, 0)()
+
+	checker := ProcessResourceChecker(ProcessMatch{Name: "worker"}, ProcessResourceThresholds{MaxCPUPercent: 90}).(*processResourceChecker)
+	clock := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	checker.now = func() time.Time { return clock }
+
+	// there is no cpu usage on the first run
+	assert.NoError(t, checker.Check())
+
+	clock = clock.Add(10 * time.Second)
+	resources[500] = ProcessResources{CPUMillis: 6000}
+	assert.NoError(t, checker.Check())
+
+	clock = clock.Add(10 * time.Second)
+	resources[500] = ProcessResources{CPUMillis: 25000}
+	assert.EqualError(t, checker.Check(), "process 500 used 190.0% cpu over 10s, above 90%")
+}
+
+func TestReadProcessResources(t *testing.T) {
+	resources, err := readProcessResources(os.Getpid())
+	require.NoError(t, err)
+	assert.True(t, resources.RSS > 0)
+	assert.True(t, resources.FDs > 0)
+	assert.True(t, resources.Threads > 0)
+	assert.True(t, resources.FDLimit > 0)
+
+	assert.Equal(t, uint64(4096), softLimit([]byte("Limit                     Soft Limit           Hard Limit           Units\n"+
+		"Max processes             unlimited            unlimited            processes\n"+
+		"Max open files            4096                 65536                files\n"), "Max open files"))
+	assert.Equal(t, uint64(0), softLimit([]byte("Max processes             unlimited            unlimited            processes\n"), "Max processes"))
+}
@@ -0,0 +1,122 @@
+package checks
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"testing"
+
+	sigar "github.com/cloudfoundry/gosigar"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeProcess struct {
+	name  string
+	state sigar.RunState
+	args  []string
+}
+
+// fakeProcesses replaces the process table with the given processes, which
+// can be changed by the test
+func fakeProcesses(processes map[int]fakeProcess) func() {
+	previousList, previousState, previousArgs := listProcesses, processState, processArgs
+	listProcesses = func() ([]int, error) {
+		var pids []int
+		for pid := range processes {
+			pids = append(pids, pid)
+		}
+		return pids, nil
+	}
+	processState = func(pid int) (sigar.ProcState, error) {
+		process, ok := processes[pid]
+		if !ok {
+			return sigar.ProcState{}, errors.New("no such process")
+		}
+		state := process.state
+		if state == 0 {
+			state = sigar.RunStateSleep
+		}
+		return sigar.ProcState{Name: process.name, State: state}, nil
+	}
+	processArgs = func(pid int) ([]string, error) {
+		return processes[pid].args, nil
+	}
+	return func() {
+		listProcesses, processState, processArgs = previousList, previousState, previousArgs
+	}
+}
+
+func TestProcessCheckerByName(t *testing.T) {
+	processes := map[int]fakeProcess{
+		1:   {name: "systemd"},
+		100: {name: "nginx", args: []string{"nginx: master process"}},
+		101: {name: "nginx", args: []string{"nginx: worker process"}},
+		102: {name: "nginx", state: sigar.RunStateZombie},
+	}
+	defer fakeProcesses(processes)()
+
+	match := ProcessMatch{Name: "nginx"}
+	pids, err := match.Pids()
+	require.NoError(t, err)
+	assert.Equal(t, []int{100, 101}, pids)
+
+	assert.NoError(t, ProcessChecker(match, 1, 2, false).Check())
+	assert.EqualError(t, ProcessChecker(match, 3, 0, false).Check(),
+		`found 2 processes matching name "nginx", expected at least 3`)
+	assert.EqualError(t, ProcessChecker(match, 1, 1, false).Check(),
+		`found 2 processes matching name "nginx", expected at most 1: [100 101]`)
+	assert.EqualError(t, ProcessChecker(ProcessMatch{Name: "haproxy"}, 1, 0, false).Check(),
+		`found 0 processes matching name "haproxy", expected at least 1`)
+}
+
+func TestProcessCheckerByCmdline(t *testing.T) {
+	defer fakeProcesses(map[int]fakeProcess{
+		200: {name: "java", args: []string{"java", "-Xmx4g", "kafka.Kafka", "server.properties"}},
+		201: {name: "java", args: []string{"java", "-Xmx1g", "org.apache.zookeeper.server.quorum.QuorumPeerMain"}},
+	})()
+
+	match := ProcessMatch{Name: "java", Cmdline: regexp.MustCompile(`kafka\.Kafka`)}
+	pids, err := match.Pids()
+	require.NoError(t, err)
+	assert.Equal(t, []int{200}, pids)
+	assert.Equal(t, `name "java" and command line "kafka\\.Kafka"`, match.String())
+}
+
+func TestProcessCheckerFailsOnRestart(t *testing.T) {
+	processes := map[int]fakeProcess{300: {name: "worker"}}
+	defer fakeProcesses(processes)()
+
+	checker := ProcessChecker(ProcessMatch{Name: "worker"}, 1, 0, true)
+	assert.NoError(t, checker.Check())
+	assert.NoError(t, checker.Check())
+
+	delete(processes, 300)
+	processes[301] = fakeProcess{name: "worker"}
+	assert.EqualError(t, checker.Check(), `processes [300] matching name "worker" are no longer running, now [301]`)
+	assert.NoError(t, checker.Check())
+}
+
+func TestProcessCheckerByPidFile(t *testing.T) {
+	sleep := exec.Command("sleep", "30")
+	require.NoError(t, sleep.Start())
+	defer sleep.Process.Kill()
+
+	pidFile, err := ioutil.TempFile("", "process")
+	require.NoError(t, err)
+	defer os.Remove(pidFile.Name())
+	pidFile.WriteString(strconv.Itoa(sleep.Process.Pid) + "\n")
+	pidFile.Close()
+
+	assert.NoError(t, ProcessChecker(ProcessMatch{PidFile: pidFile.Name(), Name: "sleep"}, 1, 1, false).Check())
+	assert.Error(t, ProcessChecker(ProcessMatch{PidFile: pidFile.Name(), Name: "nginx"}, 1, 1, false).Check())
+	assert.NoError(t, ProcessChecker(ProcessMatch{Cmdline: regexp.MustCompile(`^sleep 30$`)}, 1, 0, false).Check())
+
+	sleep.Process.Kill()
+	sleep.Wait()
+	assert.Error(t, ProcessChecker(ProcessMatch{PidFile: pidFile.Name()}, 1, 1, false).Check())
+	assert.Error(t, ProcessChecker(ProcessMatch{PidFile: "/does/not/exist.pid"}, 1, 1, false).Check())
+}
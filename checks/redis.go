@@ -0,0 +1,193 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RedisOptions configure a redis check
+type RedisOptions struct {
+	// Username and Password, if set, are sent with AUTH
+	Username string
+	Password string
+	// Role is the role the server must have: master or replica. An empty
+	// role is not checked.
+	Role string
+	// MinReplicas is the least replicas that must be connected to a master
+	MinReplicas int
+	// MaxLastIO is the longest since a replica last heard from its master. A
+	// zero threshold is not checked.
+	MaxLastIO time.Duration
+}
+
+// redisError is an error reply from redis
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// RedisChecker checks that redis responds to PING, after AUTH if a password
+// is set, within the timeout. The role and replication of the server, from
+// INFO replication, are checked against the options. A replica fails if its
+// link to the master is down.
+func RedisChecker(addr string, tlsConfig *tls.Config, options RedisOptions, timeout time.Duration) Checker {
+	return ContextCheckFunc(func(ctx context.Context) error {
+		conn, err := dial(ctx, addr, tlsConfig, timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		failed := func(command string, err error) error {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return errors.New("no response from redis at " + addr + " to " + command + " before the deadline")
+			}
+			return errors.New("redis at " + addr + " failed " + command + ": " + err.Error())
+		}
+
+		if options.Password != "" {
+			args := []string{"AUTH", options.Username, options.Password}
+			if options.Username == "" {
+				args = []string{"AUTH", options.Password}
+			}
+			if _, err := redisCommand(conn, r, args...); err != nil {
+				return failed("AUTH", err)
+			}
+		}
+		reply, err := redisCommand(conn, r, "PING")
+		if err != nil {
+			return failed("PING", err)
+		}
+		if reply != "PONG" {
+			return fmt.Errorf("redis at %s replied %q to PING", addr, reply)
+		}
+		if options.Role == "" && options.MinReplicas == 0 && options.MaxLastIO == 0 {
+			return nil
+		}
+
+		reply, err = redisCommand(conn, r, "INFO", "replication")
+		if err != nil {
+			return failed("INFO", err)
+		}
+		return checkReplication(parseRedisInfo(reply), options)
+	})
+}
+
+// checkReplication checks the INFO replication section against the options
+func checkReplication(info map[string]string, options RedisOptions) error {
+	role := info["role"]
+	if role == "slave" {
+		role = "replica"
+	}
+	if options.Role != "" && role != options.Role {
+		return fmt.Errorf("role is %s, expected %s", role, options.Role)
+	}
+
+	var failures []string
+	switch role {
+	case "master":
+		replicas, _ := strconv.Atoi(info["connected_slaves"])
+		if replicas < options.MinReplicas {
+			failures = append(failures, fmt.Sprintf("%d replicas connected, below %d", replicas, options.MinReplicas))
+		}
+	case "replica":
+		master := info["master_host"] + ":" + info["master_port"]
+		if status := info["master_link_status"]; status != "up" {
+			failure := "replication link to " + master + " is " + status
+			if seconds, err := strconv.Atoi(info["master_link_down_since_seconds"]); err == nil && seconds >= 0 {
+				failure += fmt.Sprintf(" for %s", time.Duration(seconds)*time.Second)
+			}
+			if info["master_sync_in_progress"] == "1" {
+				failure += ", sync in progress"
+			}
+			failures = append(failures, failure)
+		} else if seconds, err := strconv.Atoi(info["master_last_io_seconds_ago"]); err == nil && options.MaxLastIO > 0 {
+			if lastIO := time.Duration(seconds) * time.Second; lastIO > options.MaxLastIO {
+				failures = append(failures, fmt.Sprintf("last heard from master %s %s ago, above %s", master, lastIO, options.MaxLastIO))
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// parseRedisInfo returns the fields of an INFO reply
+func parseRedisInfo(reply string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 {
+			info[line[:i]] = line[i+1:]
+		}
+	}
+	return info
+}
+
+// redisCommand sends the command, and returns a simple, integer or bulk
+// string reply
+func redisCommand(conn net.Conn, r *bufio.Reader, args ...string) (string, error) {
+	command := fmt.Sprintf("*%d\r\n", len(args))
+	for _, arg := range args {
+		command += fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return "", err
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", redisError(line[1:])
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 {
+			return "", nil
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return "", err
+		}
+		return string(data[:length]), nil
+	}
+	return "", fmt.Errorf("unexpected reply %q", truncate([]byte(line)))
+}
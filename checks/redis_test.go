@@ -0,0 +1,118 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves AUTH, PING and INFO replication
+type fakeRedis struct {
+	mu       sync.Mutex
+	password string
+	info     string
+	args     []string
+	pending  int
+	authed   bool
+}
+
+func (f *fakeRedis) reply(line string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(line, "*"):
+		f.pending, _ = strconv.Atoi(line[1:])
+		f.args = nil
+		return ""
+	case strings.HasPrefix(line, "$"):
+		return ""
+	}
+	f.args = append(f.args, line)
+	if len(f.args) < f.pending {
+		return ""
+	}
+
+	switch strings.ToUpper(f.args[0]) {
+	case "AUTH":
+		if f.args[len(f.args)-1] != f.password {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		f.authed = true
+		return "+OK\r\n"
+	case "PING":
+		if f.password != "" && !f.authed {
+			return "-NOAUTH Authentication required.\r\n"
+		}
+		return "+PONG\r\n"
+	case "INFO":
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(f.info), f.info)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestRedisChecker(t *testing.T) {
+	fake := &fakeRedis{password: "secret", info: "# Replication\r\nrole:master\r\nconnected_slaves:2\r\n"}
+	addr, stop := lineServer(t, fake.reply)
+	defer stop()
+
+	assert.NoError(t, RedisChecker(addr, nil, RedisOptions{Password: "secret", Role: "master", MinReplicas: 2}, time.Second).Check())
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{Password: "secret", MinReplicas: 3}, time.Second).Check(),
+		"2 replicas connected, below 3")
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{Password: "secret", Role: "replica"}, time.Second).Check(),
+		"role is master, expected replica")
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{Password: "wrong"}, time.Second).Check(),
+		"redis at "+addr+" failed AUTH: WRONGPASS invalid username-password pair")
+
+	fake.mu.Lock()
+	fake.authed = false
+	fake.mu.Unlock()
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{}, time.Second).Check(),
+		"redis at "+addr+" failed PING: NOAUTH Authentication required.")
+}
+
+func TestRedisCheckerReplica(t *testing.T) {
+	fake := &fakeRedis{info: "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_port:6379\r\n" +
+		"master_link_status:up\r\nmaster_last_io_seconds_ago:3\r\n"}
+	addr, stop := lineServer(t, fake.reply)
+	defer stop()
+
+	assert.NoError(t, RedisChecker(addr, nil, RedisOptions{Role: "replica", MaxLastIO: 5 * time.Second}, time.Second).Check())
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{Role: "replica", MaxLastIO: 2 * time.Second}, time.Second).Check(),
+		"last heard from master 10.0.0.1:6379 3s ago, above 2s")
+
+	fake.mu.Lock()
+	fake.info = "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_port:6379\r\n" +
+		"master_link_status:down\r\nmaster_link_down_since_seconds:120\r\nmaster_sync_in_progress:1\r\n"
+	fake.mu.Unlock()
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{Role: "replica"}, time.Second).Check(),
+		"replication link to 10.0.0.1:6379 is down for 2m0s, sync in progress")
+}
+
+func TestRedisCheckerTimeout(t *testing.T) {
+	addr, stop := lineServer(t, func(line string) string { return "" })
+	defer stop()
+
+	assert.EqualError(t, RedisChecker(addr, nil, RedisOptions{}, 50*time.Millisecond).Check(),
+		"no response from redis at "+addr+" to PING before the deadline")
+}
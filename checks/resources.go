@@ -0,0 +1,142 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sigar "github.com/cloudfoundry/gosigar"
+)
+
+// MemoryThresholds are the limits for a memory check. A zero threshold is not
+// checked.
+type MemoryThresholds struct {
+	// MinAvailableBytes is the least memory that must be available
+	MinAvailableBytes uint64
+	// MinAvailablePercent is the least percentage of memory that must be
+	// available
+	MinAvailablePercent float64
+	// MaxSwapUsedBytes is the most swap that may be used
+	MaxSwapUsedBytes uint64
+	// MaxSwapUsedPercent is the highest percentage of swap that may be used
+	MaxSwapUsedPercent float64
+}
+
+// LoadThresholds are the limits for a load check, for the 1, 5 and 15 minute
+// load averages divided by the number of CPUs. A zero threshold is not
+// checked.
+type LoadThresholds struct {
+	MaxOne     float64
+	MaxFive    float64
+	MaxFifteen float64
+}
+
+// memoryUsage returns the memory and swap usage
+var memoryUsage = func() (sigar.Mem, sigar.Swap, error) {
+	mem, swap := sigar.Mem{}, sigar.Swap{}
+	if err := mem.Get(); err != nil {
+		return mem, swap, err
+	}
+	err := swap.Get()
+	return mem, swap, err
+}
+
+// loadAverage returns the load averages and the number of CPUs
+var loadAverage = func() (sigar.LoadAverage, int, error) {
+	load, cpus := sigar.LoadAverage{}, sigar.CpuList{}
+	if err := load.Get(); err != nil {
+		return load, 0, err
+	}
+	err := cpus.Get()
+	return load, len(cpus.List), err
+}
+
+// MemoryChecker checks the available memory and swap usage against the
+// thresholds. Available memory includes reclaimable caches.
+func MemoryChecker(thresholds MemoryThresholds) Checker {
+	return CheckFunc(func() error {
+		mem, swap, err := memoryUsage()
+		if err != nil {
+			return errors.New("unable to get memory usage: " + err.Error())
+		}
+
+		var failures []string
+		if mem.Total > 0 {
+			availablePercent := float64(mem.ActualFree) * 100 / float64(mem.Total)
+			if thresholds.MinAvailableBytes > 0 && mem.ActualFree < thresholds.MinAvailableBytes {
+				failures = append(failures, fmt.Sprintf("available memory is %s (%.1f%% of %s), below %s",
+					FormatBytes(mem.ActualFree), availablePercent, FormatBytes(mem.Total), FormatBytes(thresholds.MinAvailableBytes)))
+			}
+			if thresholds.MinAvailablePercent > 0 && availablePercent < thresholds.MinAvailablePercent {
+				failures = append(failures, fmt.Sprintf("available memory is %.1f%% of %s (%s), below %g%%",
+					availablePercent, FormatBytes(mem.Total), FormatBytes(mem.ActualFree), thresholds.MinAvailablePercent))
+			}
+		}
+		if swap.Total > 0 {
+			usedPercent := float64(swap.Used) * 100 / float64(swap.Total)
+			if thresholds.MaxSwapUsedBytes > 0 && swap.Used > thresholds.MaxSwapUsedBytes {
+				failures = append(failures, fmt.Sprintf("swap used is %s (%.1f%% of %s), above %s",
+					FormatBytes(swap.Used), usedPercent, FormatBytes(swap.Total), FormatBytes(thresholds.MaxSwapUsedBytes)))
+			}
+			if thresholds.MaxSwapUsedPercent > 0 && usedPercent > thresholds.MaxSwapUsedPercent {
+				failures = append(failures, fmt.Sprintf("swap is %.1f%% used (%s of %s), above %g%%",
+					usedPercent, FormatBytes(swap.Used), FormatBytes(swap.Total), thresholds.MaxSwapUsedPercent))
+			}
+		}
+		if len(failures) > 0 {
+			return errors.New(strings.Join(failures, "; "))
+		}
+		return nil
+	})
+}
+
+// LoadChecker checks the load averages, divided by the number of CPUs, against
+// the thresholds
+func LoadChecker(thresholds LoadThresholds) Checker {
+	return CheckFunc(func() error {
+		load, cpus, err := loadAverage()
+		if err != nil {
+			return errors.New("unable to get load average: " + err.Error())
+		}
+		if cpus < 1 {
+			cpus = 1
+		}
+
+		var failures []string
+		for _, average := range []struct {
+			minutes   int
+			load      float64
+			threshold float64
+		}{
+			{1, load.One, thresholds.MaxOne},
+			{5, load.Five, thresholds.MaxFive},
+			{15, load.Fifteen, thresholds.MaxFifteen},
+		} {
+			perCPU := average.load / float64(cpus)
+			if average.threshold > 0 && perCPU > average.threshold {
+				failures = append(failures, fmt.Sprintf("%d minute load is %.2f (%.2f per cpu on %d cpus), above %g per cpu",
+					average.minutes, average.load, perCPU, cpus, average.threshold))
+			}
+		}
+		if len(failures) > 0 {
+			return errors.New(strings.Join(failures, "; "))
+		}
+		return nil
+	})
+}
@@ -0,0 +1,63 @@
+package checks
+
+import (
+	"testing"
+
+	sigar "github.com/cloudfoundry/gosigar"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeMemoryUsage(mem sigar.Mem, swap sigar.Swap) func() {
+	previous := memoryUsage
+	memoryUsage = func() (sigar.Mem, sigar.Swap, error) {
+		return mem, swap, nil
+	}
+	return func() { memoryUsage = previous }
+}
+
+func fakeLoadAverage(load sigar.LoadAverage, cpus int) func() {
+	previous := loadAverage
+	loadAverage = func() (sigar.LoadAverage, int, error) {
+		return load, cpus, nil
+	}
+	return func() { loadAverage = previous }
+}
+
+func TestMemoryChecker(t *testing.T) {
+	// 8GiB of memory with 512MiB available, and 1GiB of 4GiB swap used
+	defer fakeMemoryUsage(
+		sigar.Mem{Total: 8 << 30, ActualFree: 512 << 20},
+		sigar.Swap{Total: 4 << 30, Used: 1 << 30},
+	)()
+
+	assert.NoError(t, MemoryChecker(MemoryThresholds{MinAvailableBytes: 256 << 20, MinAvailablePercent: 5, MaxSwapUsedPercent: 50}).Check())
+
+	assert.EqualError(t, MemoryChecker(MemoryThresholds{MinAvailableBytes: 1 << 30}).Check(),
+		"available memory is 512.0MiB (6.2% of 8.0GiB), below 1.0GiB")
+	assert.EqualError(t, MemoryChecker(MemoryThresholds{MinAvailablePercent: 10}).Check(),
+		"available memory is 6.2% of 8.0GiB (512.0MiB), below 10%")
+	assert.EqualError(t, MemoryChecker(MemoryThresholds{MaxSwapUsedBytes: 512 << 20}).Check(),
+		"swap used is 1.0GiB (25.0% of 4.0GiB), above 512.0MiB")
+	assert.EqualError(t, MemoryChecker(MemoryThresholds{MaxSwapUsedPercent: 20}).Check(),
+		"swap is 25.0% used (1.0GiB of 4.0GiB), above 20%")
+}
+
+func TestMemoryCheckerWithoutSwap(t *testing.T) {
+	defer fakeMemoryUsage(sigar.Mem{Total: 8 << 30, ActualFree: 4 << 30}, sigar.Swap{})()
+	assert.NoError(t, MemoryChecker(MemoryThresholds{MaxSwapUsedPercent: 1}).Check())
+}
+
+func TestLoadChecker(t *testing.T) {
+	defer fakeLoadAverage(sigar.LoadAverage{One: 9.4, Five: 6, Fifteen: 2}, 4)()
+
+	assert.NoError(t, LoadChecker(LoadThresholds{MaxOne: 3, MaxFive: 2, MaxFifteen: 1}).Check())
+	assert.EqualError(t, LoadChecker(LoadThresholds{MaxOne: 2, MaxFive: 1.5, MaxFifteen: 1}).Check(),
+		"1 minute load is 9.40 (2.35 per cpu on 4 cpus), above 2 per cpu")
+	assert.EqualError(t, LoadChecker(LoadThresholds{MaxFive: 1, MaxFifteen: 0.25}).Check(),
+		"5 minute load is 6.00 (1.50 per cpu on 4 cpus), above 1 per cpu; 15 minute load is 2.00 (0.50 per cpu on 4 cpus), above 0.25 per cpu")
+}
+
+func TestResourceCheckersOnHost(t *testing.T) {
+	assert.NoError(t, MemoryChecker(MemoryThresholds{MinAvailableBytes: 1}).Check())
+	assert.NoError(t, LoadChecker(LoadThresholds{MaxFifteen: 1000}).Check())
+}
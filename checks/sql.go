@@ -0,0 +1,199 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// SQLOptions configure a postgres or mysql check
+type SQLOptions struct {
+	Database string
+	Username string
+	Password string
+	// Params are added to the connection string, such as sslmode for
+	// postgres or tls for mysql
+	Params map[string]string
+	// Query is run once connected, SELECT 1 by default
+	Query string
+	// Min and Max, if set, are the limits of the number returned by the
+	// query
+	Min *float64
+	Max *float64
+}
+
+// DefaultSQLQuery is the query run by a sql check without a query
+const DefaultSQLQuery = "SELECT 1"
+
+// PostgresChecker connects to the postgres server, or pgbouncer, at the
+// address and runs the query. Unless set in the params, sslmode is disable.
+func PostgresChecker(addr string, options SQLOptions, timeout time.Duration) Checker {
+	params := url.Values{"sslmode": {"disable"}}
+	for name, value := range options.Params {
+		params.Set(name, value)
+	}
+	dsn := url.URL{Scheme: "postgres", Host: addr, Path: "/" + options.Database, RawQuery: params.Encode()}
+	if options.Username != "" {
+		dsn.User = url.UserPassword(options.Username, options.Password)
+	}
+	return sqlChecker("postgres", addr, func() (*sql.DB, error) {
+		connector, err := pq.NewConnector(dsn.String())
+		if err != nil {
+			return nil, err
+		}
+		connector.Dialer(pqDialer{})
+		return sql.OpenDB(connector), nil
+	}, options, timeout)
+}
+
+// pqDialer sets the deadline of the check on the connection, as the pq
+// driver otherwise waits indefinitely for the server during startup
+type pqDialer struct{}
+
+func (pqDialer) Dial(network, address string) (net.Conn, error) {
+	return net.Dial(network, address)
+}
+
+func (pqDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, address, timeout)
+}
+
+func (pqDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	setDeadline(ctx, conn, 0)
+	return conn, nil
+}
+
+// MySQLChecker connects to the mysql server, or ProxySQL, at the address and
+// runs the query
+func MySQLChecker(addr string, options SQLOptions, timeout time.Duration) Checker {
+	conf := mysql.NewConfig()
+	conf.Net, conf.Addr, conf.DBName = "tcp", addr, options.Database
+	conf.User, conf.Passwd = options.Username, options.Password
+	conf.Timeout = timeout
+	dsn := conf.FormatDSN()
+	if len(options.Params) > 0 {
+		params := url.Values{}
+		for name, value := range options.Params {
+			params.Set(name, value)
+		}
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + params.Encode()
+	}
+	return sqlChecker("mysql", addr, func() (*sql.DB, error) {
+		return sql.Open("mysql", dsn)
+	}, options, timeout)
+}
+
+// sqlChecker opens a connection to the database, and runs the query, within
+// the timeout. A failure to connect is reported separately from a failure of
+// the query.
+func sqlChecker(name, addr string, open func() (*sql.DB, error), options SQLOptions, timeout time.Duration) Checker {
+	query := options.Query
+	if query == "" {
+		query = DefaultSQLQuery
+	}
+	return ContextCheckFunc(func(ctx context.Context) error {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		db, err := open()
+		if err != nil {
+			return errors.New("invalid " + name + " connection: " + err.Error())
+		}
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+
+		conn, err := db.Conn(ctx)
+		if err == nil {
+			err = conn.PingContext(ctx)
+			defer conn.Close()
+		}
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("connection to %s at %s timed out after %s", name, addr, timeout)
+			}
+			return fmt.Errorf("connection to %s at %s failed: %v", name, addr, err)
+		}
+
+		var result interface{}
+		if err := conn.QueryRowContext(ctx, query).Scan(&result); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%s query timed out after %s", name, timeout)
+			}
+			return fmt.Errorf("%s query failed: %v", name, err)
+		}
+		if options.Min == nil && options.Max == nil {
+			return nil
+		}
+		return checkScalar(result, options.Min, options.Max)
+	})
+}
+
+// checkScalar checks the number returned by a query is within the limits
+func checkScalar(result interface{}, min, max *float64) error {
+	var value float64
+	switch v := result.(type) {
+	case nil:
+		return errors.New("query returned NULL")
+	case int64:
+		value = float64(v)
+	case float64:
+		value = v
+	case bool:
+		if v {
+			value = 1
+		}
+	case []byte:
+		return checkScalar(string(v), min, max)
+	case string:
+		var err error
+		if value, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+			return fmt.Errorf("query returned %q, not a number", truncate([]byte(v)))
+		}
+	default:
+		return fmt.Errorf("query returned %v, not a number", v)
+	}
+	if min != nil && value < *min {
+		return fmt.Errorf("query returned %g, below %g", value, *min)
+	}
+	if max != nil && value > *max {
+		return fmt.Errorf("query returned %g, above %g", value, *max)
+	}
+	return nil
+}
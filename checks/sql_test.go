@@ -0,0 +1,139 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSQLDriver refuses connections to the dsn "refused". Queries are
+// "SELECT <value>", returning the value, "SLEEP", which waits for the
+// deadline, or anything else, which fails.
+type fakeSQLDriver struct{}
+
+type fakeSQLConn struct{}
+
+type fakeSQLRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (fakeSQLDriver) Open(dsn string) (driver.Conn, error) {
+	if dsn == "refused" {
+		return nil, errors.New("connection refused")
+	}
+	return fakeSQLConn{}, nil
+}
+
+func (fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (fakeSQLConn) Close() error              { return nil }
+func (fakeSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (fakeSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case query == "SELECT NULL":
+		return &fakeSQLRows{}, nil
+	case strings.HasPrefix(query, "SELECT "):
+		return &fakeSQLRows{value: []byte(strings.TrimPrefix(query, "SELECT "))}, nil
+	case query == "SLEEP":
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, errors.New(`syntax error at or near "` + query + `"`)
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"value"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.value, true
+	return nil
+}
+
+func init() {
+	sql.Register("fake", fakeSQLDriver{})
+}
+
+func openFake(dsn string) func() (*sql.DB, error) {
+	return func() (*sql.DB, error) {
+		return sql.Open("fake", dsn)
+	}
+}
+
+func TestSQLChecker(t *testing.T) {
+	lag, five := 30.0, 5.0
+	assert.NoError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{}, time.Second).Check())
+	assert.NoError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{Query: "SELECT 12.5", Max: &lag}, time.Second).Check())
+	assert.EqualError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{Query: "SELECT 45", Max: &lag}, time.Second).Check(),
+		"query returned 45, above 30")
+	assert.EqualError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{Query: "SELECT 2", Min: &five}, time.Second).Check(),
+		"query returned 2, below 5")
+	assert.EqualError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{Query: "SELECT NULL", Max: &lag}, time.Second).Check(),
+		"query returned NULL")
+	assert.EqualError(t, sqlChecker("postgres", "127.0.0.1:5432", openFake("ok"), SQLOptions{Query: "SELECT master", Max: &lag}, time.Second).Check(),
+		`query returned "master", not a number`)
+}
+
+func TestSQLCheckerConnectionAndQueryErrors(t *testing.T) {
+	assert.EqualError(t, sqlChecker("mysql", "127.0.0.1:3306", openFake("refused"), SQLOptions{}, time.Second).Check(),
+		"connection to mysql at 127.0.0.1:3306 failed: connection refused")
+	assert.EqualError(t, sqlChecker("mysql", "127.0.0.1:3306", openFake("ok"), SQLOptions{Query: "SELEC 1"}, time.Second).Check(),
+		`mysql query failed: syntax error at or near "SELEC 1"`)
+	assert.EqualError(t, sqlChecker("mysql", "127.0.0.1:3306", openFake("ok"), SQLOptions{Query: "SLEEP"}, 50*time.Millisecond).Check(),
+		"mysql query timed out after 50ms")
+}
+
+func TestSQLCheckersConnectionRefused(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	err = PostgresChecker(addr, SQLOptions{Username: "healthcheck", Password: "secret"}, time.Second).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection to postgres at "+addr+" failed: ")
+	}
+	err = MySQLChecker(addr, SQLOptions{Username: "healthcheck", Password: "secret"}, time.Second).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection to mysql at "+addr+" failed: ")
+	}
+}
+
+func TestSQLCheckersConnectionTimeout(t *testing.T) {
+	addr, stop := lineServer(t, func(line string) string { return "" })
+	defer stop()
+
+	assert.EqualError(t, PostgresChecker(addr, SQLOptions{}, 50*time.Millisecond).Check(),
+		"connection to postgres at "+addr+" timed out after 50ms")
+	assert.EqualError(t, MySQLChecker(addr, SQLOptions{}, 50*time.Millisecond).Check(),
+		"connection to mysql at "+addr+" timed out after 50ms")
+}
@@ -0,0 +1,112 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// maxExpectResponse is the most of a response that is read while waiting for
+// the expected response
+const maxExpectResponse = 64 * 1024
+
+// TCPExpectChecker opens a TCP connection, optionally over TLS, sends the
+// payload and reads until the expected response is received. The whole
+// exchange must complete within the timeout.
+func TCPExpectChecker(addr string, payload []byte, expect Expect, tlsConfig *tls.Config, timeout time.Duration) Checker {
+	return ContextCheckFunc(func(ctx context.Context) error {
+		conn, err := dial(ctx, addr, tlsConfig, timeout)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		if len(payload) > 0 {
+			if _, err := conn.Write(payload); err != nil {
+				return errors.New("unable to send to " + addr + ": " + err.Error())
+			}
+		}
+		if !expect.IsSet() {
+			return nil
+		}
+		return readExpected(conn, addr, expect)
+	})
+}
+
+// dial opens a TCP or unix domain socket connection, over TLS if tlsConfig is
+// not nil, whose deadline is set to the timeout
+func dial(ctx context.Context, addr string, tlsConfig *tls.Config, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	network, address := splitAddress(addr)
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, errors.New("connection to " + addr + " failed")
+	}
+	setDeadline(ctx, conn, timeout)
+
+	if tlsConfig != nil {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, errors.New("tls handshake with " + addr + " failed: " + err.Error())
+		}
+		conn = tlsConn
+	}
+	return conn, nil
+}
+
+// setDeadline sets the deadline of the connection to the timeout, or the
+// deadline of the context if that is sooner
+func setDeadline(ctx context.Context, conn net.Conn, timeout time.Duration) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+}
+
+// readExpected reads from the connection until the response matches
+func readExpected(conn net.Conn, addr string, expect Expect) error {
+	response := make([]byte, 0, 4096)
+	buf := make([]byte, 4096)
+	for len(response) < maxExpectResponse {
+		n, err := conn.Read(buf)
+		response = append(response, buf[:n]...)
+		if expect.Matches(response) {
+			return nil
+		}
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return fmt.Errorf("no response from %s matching %s before the deadline, received %q", addr, expect, truncate(response))
+		}
+		if err == io.EOF {
+			return fmt.Errorf("connection closed by %s without a response matching %s, received %q", addr, expect, truncate(response))
+		}
+		if err != nil {
+			return errors.New("unable to receive from " + addr + ": " + err.Error())
+		}
+	}
+	return fmt.Errorf("response from %s did not match %s, received %q", addr, expect, truncate(response))
+}
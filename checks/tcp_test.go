@@ -0,0 +1,113 @@
+package checks
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineServer serves a line protocol, replying to each line with the result of
+// reply. A reply of "" sends nothing, and "close" closes the connection.
+func lineServer(t *testing.T, reply func(line string) string) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					switch response := reply(scanner.Text()); response {
+					case "":
+					case "close":
+						return
+					default:
+						fmt.Fprint(conn, response)
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func TestTCPExpectChecker(t *testing.T) {
+	addr, stop := lineServer(t, func(line string) string {
+		switch line {
+		case "version":
+			return "VERSION 1.5.6\r\n"
+		case "quit":
+			return "close"
+		}
+		return ""
+	})
+	defer stop()
+
+	assert.NoError(t, TCPExpectChecker(addr, []byte("version\r\n"), Expect{Literal: []byte("VERSION ")}, nil, time.Second).Check())
+	assert.NoError(t, TCPExpectChecker(addr, []byte("version\r\n"), Expect{Pattern: regexp.MustCompile(`^VERSION \d+\.\d+`)}, nil, time.Second).Check())
+	assert.NoError(t, TCPExpectChecker(addr, nil, Expect{}, nil, time.Second).Check())
+
+	assert.EqualError(t, TCPExpectChecker(addr, []byte("version\r\n"), Expect{Literal: []byte("STORED")}, nil, 100*time.Millisecond).Check(),
+		`no response from `+addr+` matching "STORED" before the deadline, received "VERSION 1.5.6\r\n"`)
+	assert.EqualError(t, TCPExpectChecker(addr, []byte("quit\r\n"), Expect{Literal: []byte("bye")}, nil, time.Second).Check(),
+		`connection closed by `+addr+` without a response matching "bye", received ""`)
+}
+
+func TestTCPExpectCheckerWedgedServer(t *testing.T) {
+	// accepts connections but never replies
+	addr, stop := lineServer(t, func(line string) string { return "" })
+	defer stop()
+
+	start := time.Now()
+	err := TCPExpectChecker(addr, []byte("PING\r\n"), Expect{Literal: []byte("+PONG")}, nil, 100*time.Millisecond).Check()
+	assert.EqualError(t, err, `no response from `+addr+` matching "+PONG" before the deadline, received ""`)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestTCPExpectCheckerTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	addr := ts.Listener.Addr().String()
+
+	request := []byte("GET / HTTP/1.0\r\n\r\n")
+	assert.NoError(t, TCPExpectChecker(addr, request, Expect{Literal: []byte("HTTP/1.0 200")}, &tls.Config{InsecureSkipVerify: true}, time.Second).Check())
+
+	err := TCPExpectChecker(addr, request, Expect{Literal: []byte("HTTP/1.0 200")}, &tls.Config{}, time.Second).Check()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tls handshake with "+addr+" failed")
+}
+
+func TestTCPExpectCheckerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "fpm.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			fmt.Fprint(conn, "220 ready\r\n")
+			conn.Close()
+		}
+	}()
+
+	assert.NoError(t, TCPExpectChecker("unix://"+socket, nil, Expect{Literal: []byte("220 ")}, nil, time.Second).Check())
+}
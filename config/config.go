@@ -15,25 +15,338 @@
 package config
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/robfig/cron"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+	"github.com/tootedom/ec2-local-healthchecker/health/api"
+	"github.com/tootedom/ec2-local-healthchecker/policy"
 	"gopkg.in/yaml.v2"
 )
 
 type Check struct {
-	Threshold int           `yaml:"threshold"`
-	Timeout   time.Duration `yaml:"timeout"`
-	Endpoint  string        `yaml:"endpoint"`
-	Type      string        `yaml:"type"`
-	Frequency time.Duration `yaml:"frequency"`
+	Threshold        int           `yaml:"threshold"`
+	Rise             int           `yaml:"rise"`
+	Fall             int           `yaml:"fall"`
+	Timeout          time.Duration `yaml:"timeout"`
+	Deadline         time.Duration `yaml:"deadline"`
+	Endpoint         string        `yaml:"endpoint"`
+	Host             string        `yaml:"host"`
+	Path             string        `yaml:"path"`
+	Send             string        `yaml:"send"`
+	Expect           string        `yaml:"expect"`
+	ExpectPattern    string        `yaml:"expect_pattern"`
+	Command          string        `yaml:"command"`
+	Type             string        `yaml:"type"`
+	TTL              time.Duration `yaml:"ttl"`
+	Severity         string        `yaml:"severity"`
+	DependsOn        []string      `yaml:"depends_on"`
+	Pause            bool          `yaml:"pause_when_blocked"`
+	Frequency        time.Duration `yaml:"frequency"`
+	Schedule         string        `yaml:"schedule"`
+	Immediate        bool          `yaml:"immediate"`
+	Splay            time.Duration `yaml:"splay"`
+	FailureFrequency time.Duration `yaml:"failure_frequency"`
+	Evaluator        Evaluator     `yaml:"evaluator"`
+	Flap             Flap          `yaml:"flap"`
+	Startup          Startup       `yaml:"startup"`
+	Disk             Disk          `yaml:"disk"`
+	Memory           Memory        `yaml:"memory"`
+	Load             LoadAverage   `yaml:"load"`
+	Process          Process       `yaml:"process"`
+	Resources        Resources     `yaml:"resources"`
+	DNS              DNS           `yaml:"dns"`
+	TLS              TLS           `yaml:"tls"`
+	Credentials      Credentials   `yaml:"credentials"`
+	Memcached        Memcached     `yaml:"memcached"`
+	Redis            Redis         `yaml:"redis"`
+	SQL              SQL           `yaml:"sql"`
+	GRPC             GRPC          `yaml:"grpc"`
 }
 
+// Disk configures the thresholds of a disk check: the highest percentage of
+// the filesystem used, and the least free space and inodes
+type Disk struct {
+	UsedPercent float64 `yaml:"used_percent"`
+	Free        Size    `yaml:"free"`
+	FreeInodes  uint64  `yaml:"free_inodes"`
+}
+
+// Memory configures the thresholds of a memory check: the least memory
+// available, and the most swap used
+type Memory struct {
+	Available        Size    `yaml:"available"`
+	AvailablePercent float64 `yaml:"available_percent"`
+	SwapUsed         Size    `yaml:"swap_used"`
+	SwapUsedPercent  float64 `yaml:"swap_used_percent"`
+}
+
+// LoadAverage configures the thresholds of a load check, for the 1, 5 and 15
+// minute load averages divided by the number of CPUs
+type LoadAverage struct {
+	One     float64 `yaml:"one"`
+	Five    float64 `yaml:"five"`
+	Fifteen float64 `yaml:"fifteen"`
+}
+
+// Process configures a process check. Processes are matched by the pid in
+// PidFile, the exact Name, or a Cmdline regular expression, and there must be
+// between Min (by default 1) and Max (if set) of them. FailOnRestart fails the
+// check when a process matched on the previous run is no longer running.
+type Process struct {
+	PidFile       string `yaml:"pid_file"`
+	Name          string `yaml:"name"`
+	Cmdline       string `yaml:"cmdline"`
+	Min           *int   `yaml:"min"`
+	Max           int    `yaml:"max"`
+	FailOnRestart bool   `yaml:"fail_on_restart"`
+}
+
+// MinCount returns the least number of processes that must match, defaulting
+// to 1
+func (process Process) MinCount() int {
+	if process.Min == nil {
+		return 1
+	}
+	return *process.Min
+}
+
+// Resources configures the thresholds of a process_resources check, as
+// absolute values or as a percentage of the limit: the memory of the instance
+// for RSS, and the soft limits on open files and processes for file
+// descriptors and threads. CPUPercent is the CPU used between runs, as a
+// percentage of one CPU.
+type Resources struct {
+	RSS            Size    `yaml:"rss"`
+	RSSPercent     float64 `yaml:"rss_percent"`
+	CPUPercent     float64 `yaml:"cpu_percent"`
+	FDs            uint64  `yaml:"fds"`
+	FDsPercent     float64 `yaml:"fds_percent"`
+	Threads        uint64  `yaml:"threads"`
+	ThreadsPercent float64 `yaml:"threads_percent"`
+}
+
+// DNS configures a dns check: the Name to resolve against the server in the
+// endpoint, the record Type (A by default), and the Values the answer must
+// include
+type DNS struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"`
+	Values []string `yaml:"values"`
+}
+
+// Credentials are the username and password a check authenticates with. The
+// password is read from PasswordFile, if set, when the check is created.
+type Credentials struct {
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// ReadPassword returns the password, from the password file if one is set
+func (c Credentials) ReadPassword() (string, error) {
+	if c.PasswordFile == "" {
+		return c.Password, nil
+	}
+	password, err := ioutil.ReadFile(c.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(password), "\r\n"), nil
+}
+
+// Memcached configures a memcached check: the most Evictions between checks,
+// the highest ConnectionsPercent of the connection limit in use, and a Canary
+// key to set and get
+type Memcached struct {
+	Evictions          *int64  `yaml:"evictions"`
+	ConnectionsPercent float64 `yaml:"connections_percent"`
+	Canary             string  `yaml:"canary"`
+}
+
+// Redis configures a redis check: the Role the server must have, the least
+// replicas connected to a master, and the longest since a replica last heard
+// from its master
+type Redis struct {
+	Role        string        `yaml:"role"`
+	MinReplicas int           `yaml:"min_replicas"`
+	MaxLastIO   time.Duration `yaml:"max_last_io"`
+}
+
+// SQL configures a postgres or mysql check: the Database to connect to, extra
+// connection Params, the Query to run, and the Min and Max of the number it
+// returns
+type SQL struct {
+	Database string            `yaml:"database"`
+	Params   map[string]string `yaml:"params"`
+	Query    string            `yaml:"query"`
+	Min      *float64          `yaml:"min"`
+	Max      *float64          `yaml:"max"`
+}
+
+// GRPC configures a grpc check: the Service to check, or the whole server if
+// empty, and the Metadata sent with the call
+type GRPC struct {
+	Service  string            `yaml:"service"`
+	Metadata map[string]string `yaml:"metadata"`
+}
+
+// TLS configures a check to connect over TLS. The certificate of the server
+// is verified against CAFile, or the system roots, for ServerName, or the
+// host of the endpoint.
+type TLS struct {
+	Enabled            bool   `yaml:"enabled"`
+	ServerName         string `yaml:"server_name"`
+	CAFile             string `yaml:"ca_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Startup configures a startup probe for a check. The check is not judged
+// until it has passed once, or has not passed within Timeout.
+type Startup struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// Evaluator configures how the results of a check are evaluated:
+// consecutive (the default) uses rise and fall, ratio fails when Ratio of the
+// last Samples results are failures, and duration fails when the check has
+// been failing continuously for Duration.
+type Evaluator struct {
+	Type     string        `yaml:"type"`
+	Samples  int           `yaml:"samples"`
+	Ratio    float64       `yaml:"ratio"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Flap configures flap detection for a check. A check that changes state
+// Changes times within Window is held in the Hold state (failed or
+// last-stable) until it stabilises.
+type Flap struct {
+	Window  time.Duration `yaml:"window"`
+	Changes int           `yaml:"changes"`
+	Hold    string        `yaml:"hold"`
+}
+
+// Interval returns the time between runs of the check: its frequency, or for
+// a check with a cron schedule the time between the next two runs
+func (check Check) Interval() time.Duration {
+	if check.Schedule == "" {
+		return check.Frequency
+	}
+	schedule, err := ParseSchedule(check.Schedule)
+	if err != nil {
+		return check.Frequency
+	}
+	next := schedule.Next(time.Now())
+	return schedule.Next(next).Sub(next)
+}
+
+// RunDeadline returns the longest a run of the check may take before it is
+// timed out: the deadline, or a second longer than the timeout of the check,
+// or the interval between runs
+func (check Check) RunDeadline() time.Duration {
+	switch {
+	case check.Deadline > 0:
+		return check.Deadline
+	case check.Timeout > 0:
+		return check.Timeout + time.Second
+	}
+	return check.Interval()
+}
+
+// RiseCount returns the number of consecutive successes needed for a failed
+// check to recover, defaulting to the threshold
+func (check Check) RiseCount() int {
+	if check.Rise > 0 {
+		return check.Rise
+	}
+	return check.Threshold
+}
+
+// FallCount returns the number of consecutive failures needed for a check to
+// fail, defaulting to the threshold. A check with only rise set fails on its
+// first failure, as rise is otherwise ignored.
+func (check Check) FallCount() int {
+	if check.Fall > 0 {
+		return check.Fall
+	}
+	if check.Threshold == 0 && check.Rise > 0 {
+		return 1
+	}
+	return check.Threshold
+}
+
+// Action configures what is done when the health of the instance changes.
+// By default the instance health is set in the AutoScaling group; in
+// standalone mode a local exec or file action is used instead.
+type Action struct {
+	Type    string        `yaml:"type"`
+	Command string        `yaml:"command"`
+	Path    string        `yaml:"path"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// AutoScaling configures how the AutoScaling group is used. The grace period
+// can be read from the group's HealthCheckGracePeriod, and the uptime
+// measured from the launch time of the instance rather than host uptime, so
+// the values cannot drift from the group and a reboot does not restart the
+// grace period. RespectGracePeriod passes ShouldRespectGracePeriod when
+// setting the instance health.
+type AutoScaling struct {
+	GracePeriodFromGroup   bool `yaml:"graceperiod_from_group"`
+	LaunchTimeFromInstance bool `yaml:"launchtime_from_instance"`
+	RespectGracePeriod     bool `yaml:"respect_grace_period"`
+}
+
+// Maintenance configures maintenance mode. While the File exists and has not
+// expired, or after maintenance mode is started by a signal or the admin
+// command, checks keep running but no actions are taken. Duration is used
+// when no expiry is given.
+type Maintenance struct {
+	File     string        `yaml:"file"`
+	Duration time.Duration `yaml:"duration"`
+}
+
+// Blackout configures a recurring blackout window, opening on the cron
+// Schedule (in Timezone, or local time) for Duration. During the window the
+// named Checks are ignored, or if there are none no actions are taken.
+type Blackout struct {
+	Schedule string        `yaml:"schedule"`
+	Duration time.Duration `yaml:"duration"`
+	Timezone string        `yaml:"timezone"`
+	Checks   []string      `yaml:"checks"`
+}
+
+// Location returns the location the schedule is evaluated in: the Timezone,
+// or local time if it is not set. time.LoadLocation would return UTC for an
+// empty name.
+func (b Blackout) Location() (*time.Location, error) {
+	if b.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(b.Timezone)
+}
+
+// DefaultMaintenanceFile is the flag file checked for maintenance mode
+const DefaultMaintenanceFile = "/etc/ec2-local-healthchecker.maint"
+
 type Config struct {
-	Frequency   time.Duration    `yaml:"frequency"`
-	GracePeriod time.Duration    `yaml:"graceperiod"`
-	Checks      map[string]Check `yaml:"checks"`
+	Frequency   time.Duration       `yaml:"frequency"`
+	GracePeriod time.Duration       `yaml:"graceperiod"`
+	Standalone  bool                `yaml:"standalone"`
+	Action      Action              `yaml:"action"`
+	AutoScaling AutoScaling         `yaml:"autoscaling"`
+	Policy      string              `yaml:"policy"`
+	Listen      string              `yaml:"listen"`
+	Maintenance Maintenance         `yaml:"maintenance"`
+	Blackouts   map[string]Blackout `yaml:"blackouts"`
+	Checks      map[string]Check    `yaml:"checks"`
 }
 
 func Load(path string) (*Config, error) {
@@ -44,7 +357,11 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
-	config := Config{Frequency: time.Second * 10, GracePeriod: time.Minute * 5}
+	config := Config{
+		Frequency:   time.Second * 10,
+		GracePeriod: time.Minute * 5,
+		Maintenance: Maintenance{File: DefaultMaintenanceFile, Duration: time.Hour},
+	}
 
 	err = yaml.Unmarshal(yamlFile, &config)
 
@@ -52,6 +369,351 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	if err = validateAction(config.Action); err != nil {
+		return nil, err
+	}
+
+	if config.Maintenance.Duration <= 0 {
+		return nil, fmt.Errorf("maintenance duration must be positive")
+	}
+
+	for name, check := range config.Checks {
+		if err = validateCheck(check); err != nil {
+			return nil, fmt.Errorf("check %s: %v", name, err)
+		}
+		if strings.ToLower(check.Type) == "passive" && config.Listen == "" {
+			return nil, fmt.Errorf("check %s: passive checks require listen to be set", name)
+		}
+		// the endpoint is unauthenticated, so only the instance may push results
+		if strings.ToLower(check.Type) == "passive" && !api.IsLocalAddress(config.Listen) {
+			return nil, fmt.Errorf("check %s: passive checks require listen to be a unix socket or loopback address", name)
+		}
+	}
+
+	for name, blackout := range config.Blackouts {
+		if err = validateBlackout(blackout, config.Checks); err != nil {
+			return nil, fmt.Errorf("blackout %s: %v", name, err)
+		}
+	}
+
+	if err = validateDependencies(config.Checks); err != nil {
+		return nil, err
+	}
+
+	if err = validatePolicy(config.Policy, config.Checks); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 
 }
+
+func validateAction(action Action) error {
+	switch action.Type {
+	case "", "asg":
+	case "exec":
+		if action.Command == "" {
+			return fmt.Errorf("action of type exec requires a command")
+		}
+	case "file":
+		if action.Path == "" {
+			return fmt.Errorf("action of type file requires a path")
+		}
+	default:
+		return fmt.Errorf("unknown action type: %s", action.Type)
+	}
+	return nil
+}
+
+func validateCheck(check Check) error {
+	checkType := strings.ToLower(check.Type)
+	if check.Threshold < 0 || check.Rise < 0 || check.Fall < 0 {
+		return fmt.Errorf("threshold, rise and fall cannot be negative")
+	}
+	switch check.Severity {
+	case "", "critical", "warning":
+	default:
+		return fmt.Errorf("unknown severity: %s", check.Severity)
+	}
+	if checkType == "exec" && check.Command == "" {
+		return fmt.Errorf("exec check requires a command")
+	}
+	if check.Schedule != "" {
+		if check.Frequency > 0 {
+			return fmt.Errorf("frequency and schedule cannot both be set")
+		}
+		if _, err := ParseSchedule(check.Schedule); err != nil {
+			return fmt.Errorf("invalid schedule: %v", err)
+		}
+	}
+	if check.Splay < 0 || check.FailureFrequency < 0 || check.Deadline < 0 {
+		return fmt.Errorf("splay, failure_frequency and deadline cannot be negative")
+	}
+	if checkType == "disk" {
+		if check.Path == "" {
+			return fmt.Errorf("disk check requires a path")
+		}
+		if check.Disk.UsedPercent < 0 || check.Disk.UsedPercent > 100 {
+			return fmt.Errorf("disk used_percent must be between 0 and 100")
+		}
+		if check.Disk == (Disk{}) {
+			return fmt.Errorf("disk check requires a used_percent, free or free_inodes threshold")
+		}
+	}
+	if checkType == "memory" {
+		if check.Memory.AvailablePercent < 0 || check.Memory.AvailablePercent > 100 ||
+			check.Memory.SwapUsedPercent < 0 || check.Memory.SwapUsedPercent > 100 {
+			return fmt.Errorf("memory percentages must be between 0 and 100")
+		}
+		if check.Memory == (Memory{}) {
+			return fmt.Errorf("memory check requires an available, available_percent, swap_used or swap_used_percent threshold")
+		}
+	}
+	if checkType == "load" {
+		if check.Load.One < 0 || check.Load.Five < 0 || check.Load.Fifteen < 0 {
+			return fmt.Errorf("load thresholds cannot be negative")
+		}
+		if check.Load == (LoadAverage{}) {
+			return fmt.Errorf("load check requires a one, five or fifteen threshold")
+		}
+	}
+	if checkType == "process" || checkType == "process_resources" {
+		if err := validateProcess(check.Process); err != nil {
+			return err
+		}
+	}
+	if checkType == "process_resources" {
+		r := check.Resources
+		if r.RSSPercent < 0 || r.RSSPercent > 100 || r.FDsPercent < 0 || r.FDsPercent > 100 ||
+			r.ThreadsPercent < 0 || r.ThreadsPercent > 100 || r.CPUPercent < 0 {
+			return fmt.Errorf("resource percentages must be between 0 and 100")
+		}
+		if r == (Resources{}) {
+			return fmt.Errorf("process_resources check requires a resources threshold")
+		}
+	}
+	if checkType == "dns" {
+		if check.Endpoint == "" || check.DNS.Name == "" {
+			return fmt.Errorf("dns check requires an endpoint and a name")
+		}
+		if !validRecordType(check.DNS.Type) {
+			return fmt.Errorf("unsupported dns record type: %s", check.DNS.Type)
+		}
+	}
+	switch checkType {
+	case "udp", "memcached", "redis", "postgres", "mysql", "grpc":
+		if check.Endpoint == "" {
+			return fmt.Errorf("%s check requires an endpoint", checkType)
+		}
+	}
+	if strings.HasPrefix(check.Endpoint, "unix://") {
+		if check.Endpoint == "unix://" {
+			return fmt.Errorf("unix socket endpoint requires a path")
+		}
+		if check.TLS.Enabled && check.TLS.ServerName == "" && !check.TLS.InsecureSkipVerify {
+			return fmt.Errorf("tls over a unix socket requires a server_name")
+		}
+		if (checkType == "" || checkType == "http") && check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+			return fmt.Errorf("http check path must start with /")
+		}
+	}
+	if check.SQL.Min != nil && check.SQL.Max != nil && *check.SQL.Min > *check.SQL.Max {
+		return fmt.Errorf("sql min cannot be greater than max")
+	}
+	if checkType == "memcached" {
+		if (check.Memcached.Evictions != nil && *check.Memcached.Evictions < 0) ||
+			check.Memcached.ConnectionsPercent < 0 || check.Memcached.ConnectionsPercent > 100 {
+			return fmt.Errorf("memcached evictions cannot be negative, and connections_percent must be between 0 and 100")
+		}
+		if strings.ContainsAny(check.Memcached.Canary, " \t\r\n") || len(check.Memcached.Canary) > 250 {
+			return fmt.Errorf("invalid memcached canary key: %q", check.Memcached.Canary)
+		}
+	}
+	if checkType == "redis" {
+		switch check.Redis.Role {
+		case "", "master", "replica":
+		default:
+			return fmt.Errorf("redis role must be master or replica")
+		}
+		if check.Redis.MinReplicas < 0 || check.Redis.MaxLastIO < 0 {
+			return fmt.Errorf("redis min_replicas and max_last_io cannot be negative")
+		}
+	}
+	if check.Credentials.PasswordFile != "" {
+		if _, err := check.Credentials.ReadPassword(); err != nil {
+			return fmt.Errorf("invalid password_file: %v", err)
+		}
+	}
+	if err := validateSendExpect(check); err != nil {
+		return err
+	}
+	if check.TLS.CAFile != "" {
+		if _, err := ioutil.ReadFile(check.TLS.CAFile); err != nil {
+			return fmt.Errorf("invalid tls ca_file: %v", err)
+		}
+	}
+	if checkType == "passive" && check.TTL <= 0 {
+		return fmt.Errorf("passive check requires a ttl")
+	}
+	switch check.Evaluator.Type {
+	case "", "consecutive":
+	case "ratio":
+		if check.Evaluator.Samples <= 0 {
+			return fmt.Errorf("ratio evaluator requires samples")
+		}
+		if check.Evaluator.Ratio <= 0 || check.Evaluator.Ratio > 1 {
+			return fmt.Errorf("ratio evaluator requires a ratio greater than 0 and at most 1")
+		}
+	case "duration":
+		if check.Evaluator.Duration <= 0 {
+			return fmt.Errorf("duration evaluator requires a duration")
+		}
+	default:
+		return fmt.Errorf("unknown evaluator type: %s", check.Evaluator.Type)
+	}
+	if check.Startup.Timeout < 0 {
+		return fmt.Errorf("startup timeout cannot be negative")
+	}
+	if check.Flap.Changes < 0 {
+		return fmt.Errorf("flap changes cannot be negative")
+	}
+	if check.Flap.Changes > 0 && check.Flap.Window <= 0 {
+		return fmt.Errorf("flap detection requires a window")
+	}
+	switch check.Flap.Hold {
+	case "", "failed", "last-stable":
+	default:
+		return fmt.Errorf("unknown flap hold state: %s", check.Flap.Hold)
+	}
+	return nil
+}
+
+func validRecordType(recordType string) bool {
+	if recordType == "" {
+		return true
+	}
+	for _, supported := range checks.DNSRecordTypes {
+		if strings.EqualFold(recordType, supported) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateSendExpect(check Check) error {
+	if _, err := checks.Unescape(check.Send); err != nil {
+		return fmt.Errorf("invalid send: %v", err)
+	}
+	if _, err := checks.Unescape(check.Expect); err != nil {
+		return fmt.Errorf("invalid expect: %v", err)
+	}
+	if _, err := regexp.Compile(check.ExpectPattern); err != nil {
+		return fmt.Errorf("invalid expect_pattern: %v", err)
+	}
+	if check.Expect != "" && check.ExpectPattern != "" {
+		return fmt.Errorf("expect and expect_pattern cannot both be set")
+	}
+	return nil
+}
+
+func validateProcess(process Process) error {
+	if process.PidFile == "" && process.Name == "" && process.Cmdline == "" {
+		return fmt.Errorf("process check requires a pid_file, name or cmdline")
+	}
+	if _, err := regexp.Compile(process.Cmdline); err != nil {
+		return fmt.Errorf("invalid process cmdline: %v", err)
+	}
+	if process.MinCount() < 0 || process.Max < 0 {
+		return fmt.Errorf("process min and max cannot be negative")
+	}
+	if process.Max > 0 && process.Max < process.MinCount() {
+		return fmt.Errorf("process max cannot be less than min")
+	}
+	return nil
+}
+
+func validateBlackout(blackout Blackout, checks map[string]Check) error {
+	if _, err := ParseSchedule(blackout.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %v", err)
+	}
+	if blackout.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if _, err := blackout.Location(); err != nil {
+		return fmt.Errorf("invalid timezone: %v", err)
+	}
+	for _, check := range blackout.Checks {
+		if _, ok := checks[check]; !ok {
+			return fmt.Errorf("unknown check %s", check)
+		}
+	}
+	return nil
+}
+
+// ParseSchedule parses a cron schedule: a standard 5 field spec, a 6 field
+// spec starting with seconds, or a descriptor such as @daily
+func ParseSchedule(spec string) (cron.Schedule, error) {
+	if len(strings.Fields(spec)) == 5 {
+		return cron.ParseStandard(spec)
+	}
+	return cron.Parse(spec)
+}
+
+func validatePolicy(expression string, checks map[string]Check) error {
+	if expression == "" {
+		return nil
+	}
+	p, err := policy.Parse(expression)
+	if err != nil {
+		return fmt.Errorf("policy: %v", err)
+	}
+	for _, name := range p.Names() {
+		if _, ok := checks[name]; !ok {
+			return fmt.Errorf("policy: unknown check %s", name)
+		}
+	}
+	return nil
+}
+
+// validateDependencies checks every dependency exists, and that there are no
+// cycles
+func validateDependencies(checks map[string]Check) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dependency := range checks[name].DependsOn {
+			if _, ok := checks[dependency]; !ok {
+				return fmt.Errorf("check %s: depends on unknown check %s", name, dependency)
+			}
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -55,3 +57,598 @@ func ParseFile(yamlFile []byte) (Config, error) {
 	err := yaml.Unmarshal(yamlFile, &f)
 	return f, err
 }
+
+func writeConfig(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "config")
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+func Test_LoadValidatesAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "default", input: "checks: {}"},
+		{name: "exec", input: "standalone: true\naction:\n  type: exec\n  command: /bin/true"},
+		{name: "file", input: "standalone: true\naction:\n  type: file\n  path: /tmp/status"},
+		{name: "exec without command", input: "action:\n  type: exec", wantErr: true},
+		{name: "file without path", input: "action:\n  type: file", wantErr: true},
+		{name: "unknown", input: "action:\n  type: carrier-pigeon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.input)
+			defer os.Remove(path)
+			conf, err := Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.NotNil(t, conf)
+			}
+		})
+	}
+}
+
+func Test_RiseAndFallDefaultToThreshold(t *testing.T) {
+	assert.Equal(t, 4, Check{Threshold: 4}.RiseCount())
+	assert.Equal(t, 4, Check{Threshold: 4}.FallCount())
+	assert.Equal(t, 2, Check{Threshold: 4, Rise: 2}.RiseCount())
+	assert.Equal(t, 6, Check{Threshold: 4, Fall: 6}.FallCount())
+	assert.Equal(t, 0, Check{}.FallCount())
+}
+
+func Test_FallDefaultsToOneWithOnlyRise(t *testing.T) {
+	assert.Equal(t, 3, Check{Rise: 3}.RiseCount())
+	assert.Equal(t, 1, Check{Rise: 3}.FallCount())
+}
+
+func Test_LoadValidatesFlap(t *testing.T) {
+	path := writeConfig(t, "checks:\n  nginx:\n    type: http\n    flap:\n      window: 5m\n      changes: 4\n      hold: last-stable")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Flap{Window: 5 * time.Minute, Changes: 4, Hold: "last-stable"}, conf.Checks["nginx"].Flap)
+
+	path = writeConfig(t, "checks:\n  nginx:\n    type: http\n    flap:\n      window: 5m\n      changes: 4\n      hold: sideways")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	path = writeConfig(t, "checks:\n  nginx:\n    type: http\n    flap:\n      changes: 4")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_LoadValidatesEvaluator(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "consecutive", input: "type: consecutive"},
+		{name: "ratio", input: "type: ratio\n      samples: 10\n      ratio: 0.7"},
+		{name: "duration", input: "type: duration\n      duration: 2m"},
+		{name: "ratio without samples", input: "type: ratio\n      ratio: 0.7", wantErr: true},
+		{name: "ratio above one", input: "type: ratio\n      samples: 10\n      ratio: 7", wantErr: true},
+		{name: "duration without duration", input: "type: duration", wantErr: true},
+		{name: "unknown", input: "type: vibes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, "checks:\n  nginx:\n    type: http\n    evaluator:\n      "+tt.input)
+			defer os.Remove(path)
+			_, err := Load(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_LoadValidatesPolicy(t *testing.T) {
+	checks := "checks:\n  nginx:\n    type: http\n  redis:\n    type: tcp\n"
+
+	path := writeConfig(t, "policy: nginx && redis\n"+checks)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "nginx && redis", conf.Policy)
+
+	path = writeConfig(t, "policy: nginx && memcached\n"+checks)
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.EqualError(t, err, "policy: unknown check memcached")
+
+	path = writeConfig(t, "policy: nginx &&\n"+checks)
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_LoadRejectsDependencyCycles(t *testing.T) {
+	path := writeConfig(t, `checks:
+  memcached:
+    type: tcp
+  app:
+    type: http
+    depends_on: [memcached]
+    pause_when_blocked: true
+  frontend:
+    type: http
+    depends_on: [app]`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"memcached"}, conf.Checks["app"].DependsOn)
+	assert.True(t, conf.Checks["app"].Pause)
+
+	path = writeConfig(t, `checks:
+  memcached:
+    type: tcp
+    depends_on: [frontend]
+  app:
+    type: http
+    depends_on: [memcached]
+  frontend:
+    type: http
+    depends_on: [app]`)
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.EqualError(t, err, "dependency cycle: app -> memcached -> frontend -> app")
+
+	path = writeConfig(t, "checks:\n  app:\n    type: http\n    depends_on: [app]")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.EqualError(t, err, "dependency cycle: app -> app")
+
+	path = writeConfig(t, "checks:\n  app:\n    type: http\n    depends_on: [redis]")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.EqualError(t, err, "check app: depends on unknown check redis")
+}
+
+func Test_LoadValidatesPassiveChecks(t *testing.T) {
+	path := writeConfig(t, "listen: unix:///var/run/checker.sock\nchecks:\n  worker:\n    type: passive\n    ttl: 2m")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, conf.Checks["worker"].TTL)
+
+	path = writeConfig(t, "checks:\n  worker:\n    type: passive\n    ttl: 2m")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	path = writeConfig(t, "listen: 127.0.0.1:8880\nchecks:\n  worker:\n    type: passive")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	// the type is not case sensitive
+	path = writeConfig(t, "checks:\n  worker:\n    type: Passive\n    ttl: 2m")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	path = writeConfig(t, "listen: 127.0.0.1:8880\nchecks:\n  worker:\n    type: PASSIVE")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	// results can only be pushed from the instance
+	path = writeConfig(t, "listen: localhost:8880\nchecks:\n  worker:\n    type: passive\n    ttl: 2m")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.NoError(t, err)
+
+	path = writeConfig(t, "listen: 0.0.0.0:8880\nchecks:\n  worker:\n    type: passive\n    ttl: 2m")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.EqualError(t, err, "check worker: passive checks require listen to be a unix socket or loopback address")
+}
+
+func Test_MaintenanceDefaults(t *testing.T) {
+	path := writeConfig(t, "checks:\n  nginx:\n    type: http")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Maintenance{File: DefaultMaintenanceFile, Duration: time.Hour}, conf.Maintenance)
+
+	path = writeConfig(t, "maintenance:\n  file: /tmp/checker.maint\n  duration: 30m")
+	defer os.Remove(path)
+	conf, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Maintenance{File: "/tmp/checker.maint", Duration: 30 * time.Minute}, conf.Maintenance)
+
+	path = writeConfig(t, "maintenance:\n  duration: -1m")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_LoadValidatesBlackouts(t *testing.T) {
+	checks := "checks:\n  disk_io:\n    type: http\n"
+	path := writeConfig(t, checks+"blackouts:\n  backup:\n    schedule: 0 2 * * *\n    duration: 30m\n    timezone: Europe/London\n    checks: [disk_io]")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Blackout{Schedule: "0 2 * * *", Duration: 30 * time.Minute, Timezone: "Europe/London", Checks: []string{"disk_io"}}, conf.Blackouts["backup"])
+
+	for _, blackout := range []string{
+		"schedule: 0 2 * *\n    duration: 30m",
+		"schedule: 0 2 * * *",
+		"schedule: 0 2 * * *\n    duration: 30m\n    timezone: Mars/Olympus",
+		"schedule: 0 2 * * *\n    duration: 30m\n    checks: [redis]",
+	} {
+		path := writeConfig(t, checks+"blackouts:\n  backup:\n    "+blackout)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, blackout)
+	}
+}
+
+func Test_BlackoutLocation(t *testing.T) {
+	location, err := Blackout{}.Location()
+	require.NoError(t, err)
+	assert.Equal(t, time.Local, location)
+
+	location, err = Blackout{Timezone: "Europe/London"}.Location()
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/London", location.String())
+
+	_, err = Blackout{Timezone: "Mars/Olympus_Mons"}.Location()
+	assert.Error(t, err)
+}
+
+func Test_ParseSchedule(t *testing.T) {
+	for _, spec := range []string{"*/15 * * * *", "0 */15 * * * *", "@daily", "@every 1h"} {
+		_, err := ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+	}
+	_, err := ParseSchedule("* * *")
+	assert.Error(t, err)
+}
+
+func Test_CheckSchedule(t *testing.T) {
+	path := writeConfig(t, "checks:\n  consistency:\n    type: exec\n    command: /usr/local/bin/consistency\n    schedule: \"*/15 * * * *\"\n    threshold: 2")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, conf.Checks["consistency"].Interval())
+
+	path = writeConfig(t, "checks:\n  consistency:\n    type: http\n    schedule: \"*/15 * * * *\"\n    frequency: 10s")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+
+	path = writeConfig(t, "checks:\n  consistency:\n    type: http\n    schedule: \"every 15 minutes\"")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_RunDeadline(t *testing.T) {
+	assert.Equal(t, 5*time.Second, Check{Deadline: 5 * time.Second, Timeout: time.Second}.RunDeadline())
+	assert.Equal(t, 2*time.Second, Check{Timeout: time.Second, Frequency: 10 * time.Second}.RunDeadline())
+	assert.Equal(t, 10*time.Second, Check{Frequency: 10 * time.Second}.RunDeadline())
+}
+
+func Test_ParseSize(t *testing.T) {
+	for input, expected := range map[string]Size{
+		"512":    512,
+		"10GB":   10e9,
+		"1.5GiB": 3 << 29,
+		"256 mb": 256e6,
+		"64KiB":  64 << 10,
+		"100B":   100,
+	} {
+		size, err := ParseSize(input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+	for _, input := range []string{"", "GB", "-1GB", "ten"} {
+		_, err := ParseSize(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func Test_LoadValidatesDisk(t *testing.T) {
+	path := writeConfig(t, "checks:\n  data:\n    type: disk\n    path: /data\n    disk:\n      used_percent: 90\n      free: 10GiB\n      free_inodes: 10000")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Disk{UsedPercent: 90, Free: 10 << 30, FreeInodes: 10000}, conf.Checks["data"].Disk)
+
+	for _, check := range []string{
+		"disk:\n      used_percent: 90",
+		"path: /data",
+		"path: /data\n    disk:\n      used_percent: 120",
+		"path: /data\n    disk:\n      free: lots",
+	} {
+		path := writeConfig(t, "checks:\n  data:\n    type: disk\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadValidatesMemoryAndLoad(t *testing.T) {
+	path := writeConfig(t, `checks:
+  memory:
+    type: memory
+    memory:
+      available: 512MiB
+      swap_used_percent: 50
+  load:
+    type: load
+    load:
+      five: 2
+      fifteen: 1.5`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Memory{Available: 512 << 20, SwapUsedPercent: 50}, conf.Checks["memory"].Memory)
+	assert.Equal(t, LoadAverage{Five: 2, Fifteen: 1.5}, conf.Checks["load"].Load)
+
+	for _, check := range []string{
+		"type: memory",
+		"type: memory\n    memory:\n      available_percent: 101",
+		"type: load",
+		"type: load\n    load:\n      one: -1",
+	} {
+		path := writeConfig(t, "checks:\n  resources:\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadValidatesProcess(t *testing.T) {
+	path := writeConfig(t, "checks:\n  kafka:\n    type: process\n    process:\n      name: java\n      cmdline: kafka\\.Kafka\n      max: 1\n      fail_on_restart: true\n  workers:\n    type: process\n    process:\n      name: worker\n      min: 0")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, conf.Checks["kafka"].Process.MinCount())
+	assert.Equal(t, "kafka\\.Kafka", conf.Checks["kafka"].Process.Cmdline)
+	assert.True(t, conf.Checks["kafka"].Process.FailOnRestart)
+	assert.Equal(t, 0, conf.Checks["workers"].Process.MinCount())
+
+	for _, process := range []string{
+		"max: 2",
+		"cmdline: \"java(\"",
+		"name: worker\n      min: 3\n      max: 2",
+		"name: worker\n      min: -1",
+	} {
+		path := writeConfig(t, "checks:\n  kafka:\n    type: process\n    process:\n      "+process)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, process)
+	}
+}
+
+func Test_LoadValidatesProcessResources(t *testing.T) {
+	path := writeConfig(t, "checks:\n  app:\n    type: process_resources\n    process:\n      pid_file: /var/run/app.pid\n    resources:\n      rss: 3GiB\n      cpu_percent: 250\n      fds_percent: 80\n      threads: 2000")
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, Resources{RSS: 3 << 30, CPUPercent: 250, FDsPercent: 80, Threads: 2000}, conf.Checks["app"].Resources)
+
+	for _, check := range []string{
+		"process:\n      pid_file: /var/run/app.pid",
+		"resources:\n      threads: 2000",
+		"process:\n      pid_file: /var/run/app.pid\n    resources:\n      fds_percent: 120",
+	} {
+		path := writeConfig(t, "checks:\n  app:\n    type: process_resources\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadValidatesDNSAndUDP(t *testing.T) {
+	path := writeConfig(t, `checks:
+  dnsmasq:
+    type: dns
+    endpoint: 127.0.0.1:53
+    dns:
+      name: internal.example.com
+      type: A
+      values: [10.0.0.1]
+  statsd:
+    type: udp
+    endpoint: 127.0.0.1:8125
+    send: "healthcheck:1|c\n"
+    expect_pattern: "^ok"`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, DNS{Name: "internal.example.com", Type: "A", Values: []string{"10.0.0.1"}}, conf.Checks["dnsmasq"].DNS)
+	assert.Equal(t, "^ok", conf.Checks["statsd"].ExpectPattern)
+
+	for _, check := range []string{
+		"type: dns\n    endpoint: 127.0.0.1:53",
+		"type: dns\n    endpoint: 127.0.0.1:53\n    dns:\n      name: example.com\n      type: SOA",
+		"type: udp",
+		"type: udp\n    endpoint: 127.0.0.1:8125\n    send: 'bad\\q'",
+		"type: udp\n    endpoint: 127.0.0.1:8125\n    expect_pattern: '('",
+		"type: udp\n    endpoint: 127.0.0.1:8125\n    expect: ok\n    expect_pattern: ok",
+	} {
+		path := writeConfig(t, "checks:\n  probe:\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadTCPSendExpectWithTLS(t *testing.T) {
+	path := writeConfig(t, `checks:
+  stunnel:
+    type: tcp
+    endpoint: 127.0.0.1:6380
+    send: "PING\r\n"
+    expect: "+PONG"
+    tls:
+      enabled: true
+      server_name: redis.internal
+      insecure_skip_verify: true`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, TLS{Enabled: true, ServerName: "redis.internal", InsecureSkipVerify: true}, conf.Checks["stunnel"].TLS)
+
+	path = writeConfig(t, "checks:\n  stunnel:\n    type: tcp\n    endpoint: 127.0.0.1:6380\n    tls:\n      enabled: true\n      ca_file: /nonexistent/ca.pem")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_LoadValidatesMemcachedAndRedis(t *testing.T) {
+	passwordFile, err := ioutil.TempFile("", "redis-password")
+	require.NoError(t, err)
+	defer os.Remove(passwordFile.Name())
+	passwordFile.WriteString("secret\n")
+	passwordFile.Close()
+
+	path := writeConfig(t, `checks:
+  memcached:
+    type: memcached
+    endpoint: 127.0.0.1:11211
+    memcached:
+      evictions: 0
+      connections_percent: 80
+      canary: healthcheck
+  redis:
+    type: redis
+    endpoint: 127.0.0.1:6379
+    credentials:
+      password_file: `+passwordFile.Name()+`
+    redis:
+      role: replica
+      max_last_io: 10s`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	evictions := int64(0)
+	assert.Equal(t, Memcached{Evictions: &evictions, ConnectionsPercent: 80, Canary: "healthcheck"}, conf.Checks["memcached"].Memcached)
+	assert.Equal(t, Redis{Role: "replica", MaxLastIO: 10 * time.Second}, conf.Checks["redis"].Redis)
+	password, err := conf.Checks["redis"].Credentials.ReadPassword()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+
+	for _, check := range []string{
+		"type: memcached",
+		"type: memcached\n    endpoint: 127.0.0.1:11211\n    memcached:\n      evictions: -1",
+		"type: memcached\n    endpoint: 127.0.0.1:11211\n    memcached:\n      connections_percent: 101",
+		"type: memcached\n    endpoint: 127.0.0.1:11211\n    memcached:\n      canary: 'health check'",
+		"type: redis",
+		"type: redis\n    endpoint: 127.0.0.1:6379\n    redis:\n      role: slave",
+		"type: redis\n    endpoint: 127.0.0.1:6379\n    redis:\n      min_replicas: -1",
+		"type: redis\n    endpoint: 127.0.0.1:6379\n    credentials:\n      password_file: /nonexistent/password",
+		"type: Memcached\n    endpoint: 127.0.0.1:11211\n    memcached:\n      connections_percent: 101",
+		"type: REDIS\n    endpoint: 127.0.0.1:6379\n    redis:\n      role: slave",
+	} {
+		path := writeConfig(t, "checks:\n  probe:\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadValidatesSQL(t *testing.T) {
+	path := writeConfig(t, `checks:
+  replica:
+    type: postgres
+    endpoint: 127.0.0.1:5432
+    credentials:
+      username: healthcheck
+      password: secret
+    sql:
+      database: app
+      params:
+        sslmode: require
+      query: SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+      max: 30
+  proxysql:
+    type: mysql
+    endpoint: 127.0.0.1:6033`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	max := 30.0
+	assert.Equal(t, SQL{
+		Database: "app",
+		Params:   map[string]string{"sslmode": "require"},
+		Query:    "SELECT EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())",
+		Max:      &max,
+	}, conf.Checks["replica"].SQL)
+	assert.Equal(t, Credentials{Username: "healthcheck", Password: "secret"}, conf.Checks["replica"].Credentials)
+
+	for _, check := range []string{
+		"type: postgres",
+		"type: mysql",
+		"type: mysql\n    endpoint: 127.0.0.1:3306\n    sql:\n      min: 10\n      max: 5",
+	} {
+		path := writeConfig(t, "checks:\n  probe:\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
+
+func Test_LoadGRPC(t *testing.T) {
+	path := writeConfig(t, `checks:
+  orders:
+    type: grpc
+    endpoint: 127.0.0.1:50051
+    grpc:
+      service: orders.v1.Orders
+      metadata:
+        authorization: Bearer token
+    tls:
+      enabled: true`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, GRPC{Service: "orders.v1.Orders", Metadata: map[string]string{"authorization": "Bearer token"}}, conf.Checks["orders"].GRPC)
+
+	path = writeConfig(t, "checks:\n  orders:\n    type: grpc")
+	defer os.Remove(path)
+	_, err = Load(path)
+	assert.Error(t, err)
+}
+
+func Test_LoadUnixSocketEndpoints(t *testing.T) {
+	path := writeConfig(t, `checks:
+  docker:
+    endpoint: unix:///var/run/docker.sock
+    path: /_ping
+    host: docker
+  fpm:
+    type: tcp
+    endpoint: unix:///run/php-fpm/www.sock`)
+	defer os.Remove(path)
+	conf, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "docker", conf.Checks["docker"].Host)
+	assert.Equal(t, "/_ping", conf.Checks["docker"].Path)
+
+	for _, check := range []string{
+		"type: tcp\n    endpoint: unix://",
+		"endpoint: unix:///var/run/docker.sock\n    path: _ping",
+		"type: tcp\n    endpoint: unix:///run/stunnel.sock\n    tls:\n      enabled: true",
+	} {
+		path := writeConfig(t, "checks:\n  probe:\n    "+check)
+		defer os.Remove(path)
+		_, err := Load(path)
+		assert.Error(t, err, check)
+	}
+}
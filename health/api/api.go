@@ -0,0 +1,199 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package api provides the local HTTP endpoint, used by applications to push
+// the status of passive checks, and to read the status of the checker.
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+	"github.com/tootedom/ec2-local-healthchecker/health"
+	"github.com/tootedom/ec2-local-healthchecker/maintenance"
+)
+
+// Report is the body of a POST to /checks/<name>
+type Report struct {
+	// Status is ok, warning or fail
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Handler serves:
+//
+//	POST /checks/<name>  updates the passive check with a Report
+//	GET  /status         returns the result of status, as json
+//
+// and, once HandleMaintenance is called:
+//
+//	POST   /maintenance  starts maintenance mode, for the duration and reason
+//	                     given as form values
+//	DELETE /maintenance  ends maintenance mode
+type Handler struct {
+	passive     map[string]health.Updater
+	status      func() interface{}
+	maintenance *maintenance.Manager
+	mux         *http.ServeMux
+}
+
+// NewHandler returns a Handler that updates the given passive checks, and
+// reports the value returned by status
+func NewHandler(passive map[string]health.Updater, status func() interface{}) *Handler {
+	h := &Handler{passive: passive, status: status, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/checks/", h.handleCheck)
+	h.mux.HandleFunc("/status", h.handleStatus)
+	return h
+}
+
+// HandleMaintenance serves /maintenance, to start and end maintenance mode
+// with the manager. The endpoint is unauthenticated, so it should only be
+// served on an address for which IsLocalAddress is true.
+func (h *Handler) HandleMaintenance(manager *maintenance.Manager) {
+	h.maintenance = manager
+	h.mux.HandleFunc("/maintenance", h.handleMaintenance)
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) handleCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/checks/")
+	updater, ok := h.passive[name]
+	if !ok {
+		http.Error(w, "no passive check named "+name, http.StatusNotFound)
+		return
+	}
+
+	var report Report
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			http.Error(w, "invalid report: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		report.Status = r.FormValue("status")
+		report.Message = r.FormValue("message")
+	}
+
+	status, err := parseReport(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updater.Update(status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseReport returns the check status for the report, or an error if the
+// report is invalid
+func parseReport(report Report) (status error, err error) {
+	message := report.Message
+	switch strings.ToLower(report.Status) {
+	case "ok", "pass", "passing":
+		return nil, nil
+	case "warn", "warning":
+		if message == "" {
+			message = "reported warning"
+		}
+		return checks.Warning(message), nil
+	case "fail", "failed", "critical":
+		if message == "" {
+			message = "reported failed"
+		}
+		return errors.New(message), nil
+	}
+	return nil, errors.New("status must be ok, warning or fail")
+}
+
+func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(h.status())
+}
+
+func (h *Handler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var duration time.Duration
+		if value := r.FormValue("duration"); value != "" {
+			var err error
+			if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+				http.Error(w, "invalid duration: "+value, http.StatusBadRequest)
+				return
+			}
+		}
+		h.maintenance.Start(duration, r.FormValue("reason"), "api")
+	case http.MethodDelete:
+		if err := h.maintenance.End(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.maintenance.State())
+}
+
+// IsLocalAddress returns true if the address, as given to Listen, is a unix
+// domain socket or a loopback address, so that it can only be reached from
+// the instance
+func IsLocalAddress(address string) bool {
+	if strings.HasPrefix(address, "unix://") {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Listen listens on the address, which is either host:port or a
+// unix:///path/to.sock unix domain socket. A stale socket file is removed.
+func Listen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, "unix://") {
+		path := strings.TrimPrefix(address, "unix://")
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+	"github.com/tootedom/ec2-local-healthchecker/health"
+	"github.com/tootedom/ec2-local-healthchecker/maintenance"
+)
+
+func TestPushPassiveCheck(t *testing.T) {
+	worker := health.NewStatusUpdater()
+	handler := NewHandler(map[string]health.Updater{"worker": worker}, func() interface{} {
+		return map[string]string{"worker": "ok"}
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	response, err := http.Post(ts.URL+"/checks/worker", "application/json", strings.NewReader(`{"status":"fail","message":"queue backed up"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.EqualError(t, worker.Check(), "queue backed up")
+
+	response, err = http.PostForm(ts.URL+"/checks/worker", url.Values{"status": {"warning"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.True(t, checks.IsWarning(worker.Check()))
+
+	response, err = http.PostForm(ts.URL+"/checks/worker", url.Values{"status": {"ok"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, response.StatusCode)
+	assert.Nil(t, worker.Check())
+
+	response, err = http.PostForm(ts.URL+"/checks/worker", url.Values{"status": {"meh"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	response, err = http.PostForm(ts.URL+"/checks/nginx", url.Values{"status": {"ok"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+	response, err = http.Get(ts.URL + "/checks/worker")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, response.StatusCode)
+}
+
+func TestStatus(t *testing.T) {
+	handler := NewHandler(nil, func() interface{} {
+		return map[string]string{"worker": "ok"}
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	response, err := http.Get(ts.URL + "/status")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	var status map[string]string
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&status))
+	assert.Equal(t, map[string]string{"worker": "ok"}, status)
+}
+
+func TestListenOnUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checker.sock")
+
+	// a stale socket file is replaced
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600))
+	listener, err := Listen("unix://" + path)
+	require.NoError(t, err)
+	defer listener.Close()
+	assert.Equal(t, "unix", listener.Addr().Network())
+}
+
+func TestMaintenance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	manager := maintenance.NewManager(filepath.Join(dir, "checker.maint"), time.Hour)
+	handler := NewHandler(nil, func() interface{} { return nil })
+	handler.HandleMaintenance(manager)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	response, err := http.PostForm(ts.URL+"/maintenance", url.Values{"duration": {"10m"}, "reason": {"deploying"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	var state maintenance.State
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&state))
+	assert.True(t, state.Active)
+	assert.Equal(t, "deploying", state.Reason)
+	assert.Equal(t, "api", state.Source)
+	assert.True(t, manager.State().Active)
+
+	response, err = http.PostForm(ts.URL+"/maintenance", url.Values{"duration": {"soon"}})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+
+	request, err := http.NewRequest(http.MethodDelete, ts.URL+"/maintenance", nil)
+	require.NoError(t, err)
+	response, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.False(t, manager.State().Active)
+}
+
+func TestIsLocalAddress(t *testing.T) {
+	for address, local := range map[string]bool{
+		"unix:///var/run/checker.sock": true,
+		"127.0.0.1:8880":               true,
+		"127.0.0.2:8880":               true,
+		"[::1]:8880":                   true,
+		"localhost:8880":               true,
+		"0.0.0.0:8880":                 false,
+		":8880":                        false,
+		"10.0.0.1:8880":                false,
+		"[::]:8880":                    false,
+		"checker.internal:8880":        false,
+	} {
+		assert.Equal(t, local, IsLocalAddress(address), address)
+	}
+}
@@ -0,0 +1,111 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+// BlockedError is the status of a check that depends on a failing check. The
+// check is reported as blocked rather than failed, so that the failure is
+// only reported for its cause. Dependency is the failing check, which may be
+// a dependency of a dependency.
+type BlockedError struct {
+	Dependency string
+}
+
+// Error implements the error interface
+func (b BlockedError) Error() string {
+	return "blocked by " + b.Dependency
+}
+
+// IsBlocked returns true if the error is a BlockedError
+func IsBlocked(err error) bool {
+	_, ok := err.(BlockedError)
+	return ok
+}
+
+// DependsOn records that the named check depends on the given checks. If any
+// of them fail, or are themselves blocked, the check is blocked. Only failures
+// that affect health block: a dependency that is a warning, or reports one,
+// leaves the check with its own status. Dependencies must not be cyclic.
+func (registry *Registry) DependsOn(name string, dependencies ...string) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	check, ok := registry.registeredChecks[name]
+	if !ok {
+		panic("Check does not exist: " + name)
+	}
+	check.dependsOn = append(check.dependsOn, dependencies...)
+	registry.registeredChecks[name] = check
+}
+
+// Blocked returns true if the named check is blocked by a failing dependency
+func (registry *Registry) Blocked(name string) bool {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return IsBlocked(registry.resolve()[name])
+}
+
+// BlockedStatus returns a map with all the checks that are currently blocked,
+// and the dependency that is blocking them
+func (registry *Registry) BlockedStatus() map[string]string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	statusKeys := make(map[string]string)
+	for k, err := range registry.resolve() {
+		if IsBlocked(err) {
+			statusKeys[k] = err.Error()
+		}
+	}
+	return statusKeys
+}
+
+// resolve returns the status of every check, replacing the status of checks
+// with a failing dependency with a BlockedError. It must be called with the
+// lock held.
+func (registry *Registry) resolve() map[string]error {
+	statuses := make(map[string]error, len(registry.registeredChecks))
+	for k, v := range registry.registeredChecks {
+		statuses[k] = v.checker.Check()
+	}
+
+	resolved := make(map[string]error, len(statuses))
+	var resolveCheck func(name string) error
+	resolveCheck = func(name string) error {
+		if err, ok := resolved[name]; ok {
+			return err
+		}
+		// guard against cycles, which config validation rejects
+		resolved[name] = statuses[name]
+		for _, dependency := range registry.registeredChecks[name].dependsOn {
+			if _, ok := statuses[dependency]; !ok {
+				continue
+			}
+			err := resolveCheck(dependency)
+			if blocked, ok := err.(BlockedError); ok {
+				// report the root cause rather than the blocked dependency
+				resolved[name] = blocked
+				break
+			} else if err != nil && severityOf(registry.registeredChecks[dependency].severity, err) == SeverityCritical {
+				resolved[name] = BlockedError{Dependency: dependency}
+				break
+			}
+		}
+		return resolved[name]
+	}
+	for k := range statuses {
+		resolveCheck(k)
+	}
+	return resolved
+}
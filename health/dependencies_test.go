@@ -0,0 +1,82 @@
+package health
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+)
+
+func TestDependentChecksAreBlocked(t *testing.T) {
+	memcachedDown := true
+	memcached := checks.CheckFunc(func() error {
+		if memcachedDown {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	app := checks.CheckFunc(func() error { return errors.New("500") })
+
+	registry := NewRegistry()
+	registry.Register("memcached", memcached)
+	registry.Register("app", app)
+	registry.Register("frontend", app)
+	registry.DependsOn("app", "memcached")
+	registry.DependsOn("frontend", "app")
+
+	assert.Equal(t, map[string]string{"memcached": "connection refused"}, registry.CheckStatus())
+	assert.Equal(t, map[string]string{"app": "blocked by memcached", "frontend": "blocked by memcached"}, registry.BlockedStatus())
+	assert.True(t, registry.Blocked("app"))
+	assert.True(t, IsBlocked(registry.Statuses()["frontend"]))
+
+	memcachedDown = false
+	assert.Equal(t, map[string]string{"app": "500"}, registry.CheckStatus())
+	assert.Equal(t, map[string]string{"frontend": "blocked by app"}, registry.BlockedStatus())
+	assert.False(t, registry.Blocked("app"))
+}
+
+func TestWarningDoesNotBlock(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("memcached", checks.CheckFunc(func() error { return checks.Warning("evictions") }))
+	registry.Register("app", checks.CheckFunc(func() error { return nil }))
+	registry.DependsOn("app", "memcached")
+
+	assert.False(t, registry.Blocked("app"))
+	assert.Len(t, registry.BlockedStatus(), 0)
+}
+
+func TestWarningSeverityDependencyDoesNotBlock(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterWithSeverity("cache", checks.CheckFunc(func() error { return errors.New("connection refused") }), SeverityWarning)
+	registry.Register("app", checks.CheckFunc(func() error { return errors.New("500") }))
+	registry.Register("frontend", checks.CheckFunc(func() error { return errors.New("502") }))
+	registry.DependsOn("app", "cache")
+	registry.DependsOn("frontend", "app")
+
+	assert.Equal(t, map[string]string{"app": "500"}, registry.CheckStatus())
+	assert.Equal(t, map[string]string{"cache": "connection refused"}, registry.WarningStatus())
+	assert.Equal(t, map[string]string{"frontend": "blocked by app"}, registry.BlockedStatus())
+	assert.False(t, registry.Blocked("app"))
+}
+
+func TestPausedScheduleSkipsChecks(t *testing.T) {
+	var runs int32
+	paused := make(chan bool, 1)
+	paused <- true
+	check := checks.CheckFunc(func() error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	schedule := Schedule{Period: 10 * time.Millisecond, Paused: func() bool {
+		return <-paused
+	}}
+	ScheduledThresholdChecker(check, schedule, NewThresholdStatusUpdater(1))
+
+	paused <- false
+	paused <- false
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&runs))
+}
@@ -0,0 +1,110 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ratioUpdater implements Updater, failing the check when the ratio of
+// failures over the last samples results reaches the configured ratio. Unlike
+// the thresholdUpdater, interleaved successes do not reset the count, so
+// intermittent failures are caught.
+type ratioUpdater struct {
+	mu       sync.Mutex
+	ratio    float64
+	results  []bool
+	next     int
+	failures int
+	status   error
+}
+
+// NewRatioStatusUpdater returns an Updater that fails once the given ratio
+// (0 < ratio <= 1) of the last samples results are failures
+func NewRatioStatusUpdater(samples int, ratio float64) Updater {
+	return &ratioUpdater{ratio: ratio, results: make([]bool, samples)}
+}
+
+// Check implements the Checker interface
+func (ru *ratioUpdater) Check() error {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	if float64(ru.failures) >= ru.ratio*float64(len(ru.results)) {
+		return carrySeverity(ru.status, fmt.Errorf("%d of the last %d checks failed: %v", ru.failures, len(ru.results), ru.status))
+	}
+	return nil
+}
+
+// Update implements the Updater interface
+func (ru *ratioUpdater) Update(status error) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	if ru.results[ru.next] {
+		ru.failures--
+	}
+	ru.results[ru.next] = status != nil
+	if status != nil {
+		ru.failures++
+		ru.status = status
+	}
+	ru.next = (ru.next + 1) % len(ru.results)
+}
+
+// durationUpdater implements Updater, failing the check once it has been
+// failing continuously for at least the configured duration. A single
+// success resets it.
+type durationUpdater struct {
+	mu           sync.Mutex
+	duration     time.Duration
+	now          func() time.Time
+	failingSince time.Time
+	status       error
+}
+
+// NewDurationStatusUpdater returns an Updater that fails once the check has
+// been failing for at least d
+func NewDurationStatusUpdater(d time.Duration) Updater {
+	return &durationUpdater{duration: d, now: time.Now}
+}
+
+// Check implements the Checker interface
+func (du *durationUpdater) Check() error {
+	du.mu.Lock()
+	defer du.mu.Unlock()
+	if du.failingSince.IsZero() {
+		return nil
+	}
+	failingFor := du.now().Sub(du.failingSince)
+	if failingFor >= du.duration {
+		return carrySeverity(du.status, fmt.Errorf("failing for %s: %v", failingFor.Truncate(time.Second), du.status))
+	}
+	return nil
+}
+
+// Update implements the Updater interface
+func (du *durationUpdater) Update(status error) {
+	du.mu.Lock()
+	defer du.mu.Unlock()
+	if status == nil {
+		du.failingSince = time.Time{}
+	} else if du.failingSince.IsZero() {
+		du.failingSince = du.now()
+	}
+	du.status = status
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatioCatchesIntermittentFailures(t *testing.T) {
+	failure := errors.New("failed")
+	consecutive := NewThresholdStatusUpdater(4)
+	ratio := NewRatioStatusUpdater(10, 0.6)
+
+	// fails 7 out of every 10 checks, never more than 3 in a row
+	pattern := []bool{false, false, false, true, false, false, true, false, false, true}
+	for round := 0; round < 3; round++ {
+		for _, ok := range pattern {
+			var status error
+			if !ok {
+				status = failure
+			}
+			consecutive.Update(status)
+			ratio.Update(status)
+		}
+	}
+
+	assert.Nil(t, consecutive.Check())
+	assert.Error(t, ratio.Check())
+
+	// the oldest failure drops out of the window, 6 of 10 still failed
+	ratio.Update(nil)
+	assert.Error(t, ratio.Check())
+	ratio.Update(nil)
+	assert.Nil(t, ratio.Check())
+}
+
+func TestRatioNeedsEnoughFailures(t *testing.T) {
+	ratio := NewRatioStatusUpdater(5, 0.5)
+	ratio.Update(errors.New("failed"))
+	ratio.Update(errors.New("failed"))
+	assert.Nil(t, ratio.Check())
+	ratio.Update(errors.New("failed"))
+	assert.Error(t, ratio.Check())
+}
+
+func TestDurationFailsAfterContinuousFailure(t *testing.T) {
+	failure := errors.New("failed")
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	du := NewDurationStatusUpdater(time.Minute).(*durationUpdater)
+	du.now = clock.now
+
+	du.Update(failure)
+	clock.advance(30 * time.Second)
+	du.Update(failure)
+	assert.Nil(t, du.Check())
+
+	clock.advance(30 * time.Second)
+	du.Update(failure)
+	assert.Error(t, du.Check())
+
+	du.Update(nil)
+	assert.Nil(t, du.Check())
+
+	du.Update(failure)
+	clock.advance(59 * time.Second)
+	assert.Nil(t, du.Check())
+	clock.advance(time.Second)
+	assert.Error(t, du.Check())
+}
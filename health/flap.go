@@ -0,0 +1,115 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FlapHold is the state a flapping check is held in until it stabilises
+type FlapHold string
+
+const (
+	// FlapHoldFailed reports a flapping check as failed
+	FlapHoldFailed FlapHold = "failed"
+	// FlapHoldLastStable reports a flapping check with the status it had
+	// before it started flapping
+	FlapHoldLastStable FlapHold = "last-stable"
+)
+
+// flapDetector wraps an Updater, counting the number of times the wrapped
+// updater changes between healthy and unhealthy. If the number of changes
+// within the window reaches the limit, the check is flapping and is held in
+// the configured state until the number of changes in the window drops back
+// below the limit.
+type flapDetector struct {
+	mu          sync.Mutex
+	updater     Updater
+	window      time.Duration
+	changes     int
+	hold        FlapHold
+	now         func() time.Time
+	transitions []time.Time
+	healthy     bool
+	stable      error
+	status      error
+	flapping    bool
+}
+
+// NewFlapDetector returns an Updater that holds the wrapped updater in the
+// given state while it changes state at least changes times within window
+func NewFlapDetector(updater Updater, window time.Duration, changes int, hold FlapHold) Updater {
+	return &flapDetector{
+		updater: updater,
+		window:  window,
+		changes: changes,
+		hold:    hold,
+		now:     time.Now,
+		healthy: updater.Check() == nil,
+		stable:  updater.Check(),
+	}
+}
+
+// Check implements the Checker interface
+func (fd *flapDetector) Check() error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	fd.expireTransitions()
+
+	if !fd.flapping {
+		return fd.updater.Check()
+	}
+	if fd.hold == FlapHoldLastStable {
+		return fd.stable
+	}
+	return carrySeverity(fd.status, fmt.Errorf("Flapping: %d state changes within %s", len(fd.transitions), fd.window))
+}
+
+// Update implements the Updater interface
+func (fd *flapDetector) Update(status error) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	fd.updater.Update(status)
+	if status != nil {
+		fd.status = status
+	}
+
+	current := fd.updater.Check()
+	healthy := current == nil
+	if healthy != fd.healthy {
+		fd.healthy = healthy
+		fd.transitions = append(fd.transitions, fd.now())
+	}
+	fd.expireTransitions()
+	if !fd.flapping {
+		fd.stable = current
+	}
+}
+
+// expireTransitions forgets the state changes that are older than the window,
+// and recalculates whether the check is flapping
+func (fd *flapDetector) expireTransitions() {
+	cutoff := fd.now().Add(-fd.window)
+	expired := 0
+	for expired < len(fd.transitions) && !fd.transitions[expired].After(cutoff) {
+		expired++
+	}
+	fd.transitions = fd.transitions[expired:]
+	fd.flapping = len(fd.transitions) >= fd.changes
+}
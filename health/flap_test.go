@@ -0,0 +1,77 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeTime struct {
+	current time.Time
+}
+
+func (ft *fakeTime) now() time.Time {
+	return ft.current
+}
+
+func (ft *fakeTime) advance(d time.Duration) {
+	ft.current = ft.current.Add(d)
+}
+
+func newTestFlapDetector(clock *fakeTime, hold FlapHold) Updater {
+	fd := NewFlapDetector(NewRiseFallStatusUpdater(1, 1), time.Minute, 3, hold).(*flapDetector)
+	fd.now = clock.now
+	return fd
+}
+
+func TestFlappingCheckIsHeldFailed(t *testing.T) {
+	failure := errors.New("failed")
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	fd := newTestFlapDetector(clock, FlapHoldFailed)
+
+	fd.Update(failure)
+	assert.Equal(t, failure, fd.Check())
+	clock.advance(time.Second)
+	fd.Update(nil)
+	assert.Nil(t, fd.Check())
+	clock.advance(time.Second)
+	fd.Update(failure)
+	clock.advance(time.Second)
+	fd.Update(nil)
+
+	// three changes within the window, so held as failed despite passing
+	err := fd.Check()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Flapping")
+
+	// stable for the window, so no longer flapping
+	clock.advance(time.Minute)
+	fd.Update(nil)
+	assert.Nil(t, fd.Check())
+}
+
+func TestFlappingCheckIsHeldLastStable(t *testing.T) {
+	failure := errors.New("failed")
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	fd := newTestFlapDetector(clock, FlapHoldLastStable)
+
+	fd.Update(nil)
+	fd.Update(failure)
+	clock.advance(time.Second)
+	fd.Update(nil)
+	clock.advance(time.Second)
+	assert.Nil(t, fd.Check())
+
+	// third change starts flapping, the check stays at the last stable state
+	fd.Update(failure)
+	assert.Nil(t, fd.Check())
+	clock.advance(time.Second)
+	fd.Update(nil)
+	fd.Update(failure)
+	assert.Nil(t, fd.Check())
+
+	clock.advance(time.Minute)
+	assert.Equal(t, failure, fd.Check())
+}
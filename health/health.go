@@ -19,7 +19,6 @@ package health
 import (
 	"errors"
 	"sync"
-	"time"
 
 	"github.com/tootedom/ec2-local-healthchecker/checks"
 )
@@ -29,7 +28,24 @@ import (
 // separate registries to isolate themselves from other tests.
 type Registry struct {
 	mu               sync.RWMutex
-	registeredChecks map[string]checks.Checker
+	registeredChecks map[string]registeredCheck
+}
+
+// Severity is how a failure of a check is treated. Only failures of critical
+// checks affect the health of the instance; warnings are only reported.
+type Severity string
+
+const (
+	// SeverityCritical checks decide the health of the instance
+	SeverityCritical Severity = "critical"
+	// SeverityWarning checks are only reported
+	SeverityWarning Severity = "warning"
+)
+
+type registeredCheck struct {
+	checker   checks.Checker
+	severity  Severity
+	dependsOn []string
 }
 
 // NewRegistry creates a new registry. This isn't necessary for normal use of
@@ -37,7 +53,7 @@ type Registry struct {
 // own set of checks.
 func NewRegistry() *Registry {
 	return &Registry{
-		registeredChecks: make(map[string]checks.Checker),
+		registeredChecks: make(map[string]registeredCheck),
 	}
 }
 
@@ -70,14 +86,33 @@ func (u *updater) Check() error {
 	return u.status
 }
 
+// Update implements the Updater interface, allowing asynchronous access to
+// the status of a Checker.
+func (u *updater) Update(status error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.status = status
+}
+
+// NewStatusUpdater returns a new updater, whose status is whatever it was last
+// updated with
+func NewStatusUpdater() Updater {
+	return &updater{}
+}
+
 // thresholdUpdater implements Checker and Updater, providing an asynchronous Update
 // method.
 // This allows us to have a Checker that returns the Check() call immediately
 // not blocking on a potentially expensive check.
+// The check becomes unhealthy after fall consecutive failures, and healthy
+// again after rise consecutive successes.
 type thresholdUpdater struct {
 	mu           sync.Mutex
 	status       error
-	threshold    int
+	lastFailure  error
+	rise         int
+	fall         int
 	failedCount  int
 	successCount int
 	isError      bool
@@ -87,17 +122,10 @@ type thresholdUpdater struct {
 func (tu *thresholdUpdater) Check() error {
 	tu.mu.Lock()
 	defer tu.mu.Unlock()
-	// todo if both less than return nil
-	if tu.failedCount < tu.threshold && tu.successCount < tu.threshold {
-		if tu.isError {
-			return errors.New("Previous Ill, and not yet Healthy")
-		} else {
-			return nil
-		}
-	} else if tu.failedCount >= tu.threshold {
+	if tu.failedCount >= tu.fall {
 		return tu.status
-	} else if tu.successCount < tu.threshold {
-		return errors.New("Consecutive Success less than threshold")
+	} else if tu.isError {
+		return carrySeverity(tu.lastFailure, errors.New("Previous Ill, and not yet Healthy"))
 	}
 
 	return nil
@@ -110,51 +138,65 @@ func (tu *thresholdUpdater) Update(status error) {
 	defer tu.mu.Unlock()
 	if status == nil {
 		tu.successCount++
-		if tu.successCount >= tu.threshold {
+		if tu.successCount >= tu.rise {
 			tu.isError = false
 		}
 		tu.failedCount = 0
 	} else {
-		if tu.failedCount < tu.threshold {
+		if tu.failedCount < tu.fall {
 			tu.failedCount++
 		}
-		if tu.failedCount >= tu.threshold {
+		if tu.failedCount >= tu.fall {
 			tu.isError = true
 		}
 		tu.successCount = 0
+		tu.lastFailure = status
 	}
 
 	tu.status = status
 }
 
-// NewThresholdStatusUpdater returns a new thresholdUpdater
+// NewThresholdStatusUpdater returns a new thresholdUpdater that uses the same
+// threshold for both failure and recovery
 func NewThresholdStatusUpdater(t int) Updater {
-	return &thresholdUpdater{threshold: t}
-}
-
-// PeriodicThresholdChecker wraps an updater to provide a periodic checker that
-// uses a threshold before it changes status
-func PeriodicThresholdChecker(check checks.Checker, period time.Duration, threshold int) checks.Checker {
-	tu := NewThresholdStatusUpdater(threshold)
-	go func() {
-		t := time.NewTicker(period)
-		for {
-			<-t.C
-			tu.Update(check.Check())
-		}
-	}()
+	return NewRiseFallStatusUpdater(t, t)
+}
 
-	return tu
+// NewRiseFallStatusUpdater returns a new thresholdUpdater that is failed
+// after fall consecutive failures, and recovers after rise consecutive
+// successes
+func NewRiseFallStatusUpdater(rise int, fall int) Updater {
+	return &thresholdUpdater{rise: rise, fall: fall}
 }
 
-// CheckStatus returns a map with all the current health check errors
+// carrySeverity returns err as a warning if the status it was derived from
+// was a warning, so that updaters reporting their own error keep the severity
+// of the check result
+func carrySeverity(status error, err error) error {
+	if checks.IsWarning(status) {
+		return checks.Warning(err.Error())
+	}
+	return err
+}
+
+// CheckStatus returns a map with all the current critical health check errors.
+// Warnings, from warning severity checks or from checks reporting a warning,
+// are not included.
 func (registry *Registry) CheckStatus() map[string]string { // TODO(stevvooe) this needs a proper type
+	return registry.status(SeverityCritical)
+}
+
+// WarningStatus returns a map with all the current health check warnings
+func (registry *Registry) WarningStatus() map[string]string {
+	return registry.status(SeverityWarning)
+}
+
+func (registry *Registry) status(severity Severity) map[string]string {
 	registry.mu.RLock()
 	defer registry.mu.RUnlock()
 	statusKeys := make(map[string]string)
-	for k, v := range registry.registeredChecks {
-		err := v.Check()
-		if err != nil {
+	for k, err := range registry.resolve() {
+		if err != nil && !IsBlocked(err) && severityOf(registry.registeredChecks[k].severity, err) == severity {
 			statusKeys[k] = err.Error()
 		}
 	}
@@ -162,14 +204,37 @@ func (registry *Registry) CheckStatus() map[string]string { // TODO(stevvooe) th
 	return statusKeys
 }
 
+// Statuses returns the current status of every registered check. Checks that
+// are blocked by a failing dependency have a BlockedError status.
+func (registry *Registry) Statuses() map[string]error {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return registry.resolve()
+}
+
+// severityOf returns the severity of a check error; a critical check that
+// reports a warning is only a warning
+func severityOf(severity Severity, err error) Severity {
+	if checks.IsWarning(err) {
+		return SeverityWarning
+	}
+	return severity
+}
+
 // CheckStatus returns a map with all the current health check errors from the
 // default registry.
 func CheckStatus() map[string]string {
 	return DefaultRegistry.CheckStatus()
 }
 
-// Register associates the checker with the provided name.
+// Register associates the critical checker with the provided name.
 func (registry *Registry) Register(name string, check checks.Checker) {
+	registry.RegisterWithSeverity(name, check, SeverityCritical)
+}
+
+// RegisterWithSeverity associates the checker with the provided name, with the
+// given severity.
+func (registry *Registry) RegisterWithSeverity(name string, check checks.Checker, severity Severity) {
 	if registry == nil {
 		registry = DefaultRegistry
 	}
@@ -179,5 +244,5 @@ func (registry *Registry) Register(name string, check checks.Checker) {
 	if ok {
 		panic("Check already exists: " + name)
 	}
-	registry.registeredChecks[name] = check
+	registry.registeredChecks[name] = registeredCheck{checker: check, severity: severity}
 }
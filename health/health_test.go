@@ -1,6 +1,7 @@
 package health
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -8,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/robfig/cron"
 	"github.com/stretchr/testify/assert"
 	"github.com/tootedom/ec2-local-healthchecker/checks"
 )
@@ -25,7 +27,7 @@ func TestConsecutiveSuccess(t *testing.T) {
 
 	// Check that is expecting 500 responses
 	defaultRegistry := NewRegistry()
-	defaultRegistry.Register("failing", PeriodicThresholdChecker(checks.HTTPChecker(ts.URL, 200, time.Second*1, nil), time.Second*1, 10))
+	defaultRegistry.Register("failing", PeriodicThresholdChecker(checks.HTTPChecker(ts.URL, 200, time.Second*1, nil), time.Second*1, NewThresholdStatusUpdater(10)))
 
 	time.Sleep(5 * time.Second)
 
@@ -60,7 +62,7 @@ func TestConsecutiveFailures(t *testing.T) {
 
 	// Check that is expecting 200 responses
 	defaultRegistry := NewRegistry()
-	defaultRegistry.Register("failing", PeriodicThresholdChecker(checks.HTTPChecker(ts.URL, 200, time.Second*1, nil), time.Second*1, 3))
+	defaultRegistry.Register("failing", PeriodicThresholdChecker(checks.HTTPChecker(ts.URL, 200, time.Second*1, nil), time.Second*1, NewThresholdStatusUpdater(3)))
 
 	time.Sleep(5 * time.Second)
 
@@ -76,3 +78,65 @@ func TestConsecutiveFailures(t *testing.T) {
 	assert.True(t, len(checksFailed) == 0)
 
 }
+
+func TestRiseAndFallThresholds(t *testing.T) {
+	failure := errors.New("failed")
+	tu := NewRiseFallStatusUpdater(3, 2)
+
+	tu.Update(failure)
+	assert.Nil(t, tu.Check())
+	tu.Update(failure)
+	assert.Equal(t, failure, tu.Check())
+
+	tu.Update(nil)
+	assert.Error(t, tu.Check())
+	tu.Update(nil)
+	assert.Error(t, tu.Check())
+	tu.Update(nil)
+	assert.Nil(t, tu.Check())
+
+	tu.Update(failure)
+	assert.Nil(t, tu.Check())
+}
+
+func TestWarningsDoNotFailRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("ok", checks.CheckFunc(func() error { return nil }))
+	registry.Register("critical", checks.CheckFunc(func() error { return errors.New("down") }))
+	registry.Register("degraded", checks.CheckFunc(func() error { return checks.Warning("slow") }))
+	registry.RegisterWithSeverity("optional", checks.CheckFunc(func() error { return errors.New("down") }), SeverityWarning)
+
+	assert.Equal(t, map[string]string{"critical": "down"}, registry.CheckStatus())
+	assert.Equal(t, map[string]string{"degraded": "slow", "optional": "down"}, registry.WarningStatus())
+}
+
+func TestThresholdKeepsWarningSeverity(t *testing.T) {
+	tu := NewRiseFallStatusUpdater(2, 1)
+	tu.Update(checks.Warning("slow"))
+	assert.True(t, checks.IsWarning(tu.Check()))
+	tu.Update(nil)
+	assert.True(t, checks.IsWarning(tu.Check()))
+	tu.Update(nil)
+	assert.Nil(t, tu.Check())
+}
+
+func TestCronScheduleKeepsThreshold(t *testing.T) {
+	runs := make(chan bool, 10)
+	check := checks.CheckFunc(func() error {
+		runs <- true
+		return errors.New("inconsistent")
+	})
+	everySecond, err := cron.Parse("* * * * * *")
+	assert.NoError(t, err)
+	updater := ScheduledThresholdChecker(check, Schedule{Cron: everySecond}, NewThresholdStatusUpdater(2))
+
+	start := time.Now()
+	<-runs
+	assert.True(t, time.Since(start) <= time.Second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Nil(t, updater.Check())
+
+	<-runs
+	time.Sleep(10 * time.Millisecond)
+	assert.EqualError(t, updater.Check(), "inconsistent")
+}
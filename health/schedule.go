@@ -0,0 +1,211 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+)
+
+// Schedule configures when a periodic check is run
+type Schedule struct {
+	// Period is the time between each run of the check
+	Period time.Duration
+	// Cron, if set, is used instead of Period to run the check at the times
+	// of a cron schedule
+	Cron cron.Schedule
+	// Immediate runs the check as soon as it is scheduled, rather than
+	// waiting for the first period
+	Immediate bool
+	// Splay delays each run by a random amount up to Splay, so that instances
+	// do not all run a check at the same time
+	Splay time.Duration
+	// FailurePeriod, if set, is the time between runs after the check has
+	// failed, so that a failure is confirmed or cleared quickly
+	FailurePeriod time.Duration
+	// Deadline, if set, is the longest a run may take. A run that overruns it
+	// has a checks.TimeoutError status, and a ContextChecker is cancelled.
+	Deadline time.Duration
+	// Paused, if set, is called before each run; the run is skipped while it
+	// returns true
+	Paused func() bool
+	// Runs, if set, counts the runs that were skipped or timed out
+	Runs *Runs
+
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+	random func(int64) int64
+}
+
+// Runs counts the runs of a scheduled check that were skipped, because a
+// previous run overran, and that timed out
+type Runs struct {
+	skipped  int64
+	timedOut int64
+}
+
+// Skipped returns the number of runs that were skipped
+func (r *Runs) Skipped() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.skipped)
+}
+
+// TimedOut returns the number of runs that timed out
+func (r *Runs) TimedOut() int64 {
+	if r == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.timedOut)
+}
+
+func (r *Runs) skip(n int64) {
+	if r != nil && n > 0 {
+		atomic.AddInt64(&r.skipped, n)
+	}
+}
+
+func (r *Runs) timeout() {
+	if r != nil {
+		atomic.AddInt64(&r.timedOut, 1)
+	}
+}
+
+// PeriodicThresholdChecker wraps an updater to provide a periodic checker that
+// uses the updater's threshold before it changes status
+func PeriodicThresholdChecker(check checks.Checker, period time.Duration, tu Updater) checks.Checker {
+	return ScheduledThresholdChecker(check, Schedule{Period: period}, tu)
+}
+
+// ScheduledThresholdChecker wraps an updater to provide a checker that is run
+// according to the schedule, and uses the updater's threshold before it
+// changes status
+func ScheduledThresholdChecker(check checks.Checker, schedule Schedule, tu Updater) checks.Checker {
+	if schedule.Cron == nil && schedule.Period <= 0 {
+		panic("non-positive period for a scheduled check")
+	}
+	if schedule.now == nil {
+		schedule.now = time.Now
+	}
+	if schedule.after == nil {
+		schedule.after = time.After
+	}
+	if schedule.random == nil {
+		schedule.random = rand.Int63n
+	}
+	go schedule.run(check, tu)
+	return tu
+}
+
+// run runs the check on the schedule. Runs never overlap: runs that are
+// missed while a previous run is in progress, or has timed out but not yet
+// returned, are skipped.
+func (s Schedule) run(check checks.Checker, tu Updater) {
+	next := s.now()
+	if !s.Immediate {
+		next = s.next(next, false)
+	}
+	failed := false
+	var running <-chan struct{}
+	for {
+		<-s.after(next.Add(s.splay()).Sub(s.now()))
+		if isRunning(running) {
+			s.Runs.skip(1)
+		} else if s.Paused == nil || !s.Paused() {
+			var status error
+			running, status = s.runCheck(check)
+			tu.Update(status)
+			failed = status != nil
+		}
+
+		now := s.now()
+		next = s.next(next, failed)
+		for !next.After(now) {
+			next = s.next(next, failed)
+			s.Runs.skip(1)
+		}
+	}
+}
+
+// runCheck runs the check within the deadline. It returns a channel that is
+// closed when the check returns, which may be after the deadline.
+func (s Schedule) runCheck(check checks.Checker) (<-chan struct{}, error) {
+	done := make(chan struct{})
+	if s.Deadline <= 0 {
+		defer close(done)
+		return done, check.Check()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Deadline)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		defer close(done)
+		if cc, ok := check.(checks.ContextChecker); ok {
+			result <- cc.CheckContext(ctx)
+		} else {
+			result <- check.Check()
+		}
+	}()
+
+	var status error
+	select {
+	case status = <-result:
+	case <-ctx.Done():
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		s.Runs.timeout()
+		return done, checks.TimeoutError{Deadline: s.Deadline}
+	}
+	return done, status
+}
+
+func isRunning(running <-chan struct{}) bool {
+	if running == nil {
+		return false
+	}
+	select {
+	case <-running:
+		return false
+	default:
+		return true
+	}
+}
+
+// next returns the time of the run after t
+func (s Schedule) next(t time.Time, failed bool) time.Time {
+	switch {
+	case failed && s.FailurePeriod > 0:
+		return t.Add(s.FailurePeriod)
+	case s.Cron != nil:
+		return s.Cron.Next(t)
+	}
+	return t.Add(s.Period)
+}
+
+func (s Schedule) splay() time.Duration {
+	if s.Splay <= 0 {
+		return 0
+	}
+	return time.Duration(s.random(int64(s.Splay)))
+}
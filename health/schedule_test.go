@@ -0,0 +1,229 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+)
+
+// fakeClock is a controllable clock for schedules. Each wait the schedule
+// starts is sent to waits, and fires when the clock is advanced past it.
+type fakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+	timers  []fakeTimer
+	waits   chan time.Duration
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{current: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC), waits: make(chan time.Duration, 1)}
+}
+
+func (fc *fakeClock) now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.current
+}
+
+func (fc *fakeClock) after(d time.Duration) <-chan time.Time {
+	fc.mu.Lock()
+	c := make(chan time.Time, 1)
+	fc.timers = append(fc.timers, fakeTimer{at: fc.current.Add(d), c: c})
+	fc.mu.Unlock()
+	fc.fire()
+	fc.waits <- d
+	return c
+}
+
+// advance moves the clock forward, firing any timers that are due
+func (fc *fakeClock) advance(d time.Duration) {
+	fc.mu.Lock()
+	fc.current = fc.current.Add(d)
+	fc.mu.Unlock()
+	fc.fire()
+}
+
+func (fc *fakeClock) fire() {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	pending := fc.timers[:0]
+	for _, timer := range fc.timers {
+		if timer.at.After(fc.current) {
+			pending = append(pending, timer)
+		} else {
+			timer.c <- timer.at
+		}
+	}
+	fc.timers = pending
+}
+
+// scheduledCheck returns a check reporting each of results in turn, and a
+// channel that receives each result as it is reported
+func scheduledCheck(results ...error) (checks.Checker, chan error) {
+	runs := make(chan error, len(results))
+	i := 0
+	return checks.CheckFunc(func() error {
+		result := results[i%len(results)]
+		i++
+		runs <- result
+		return result
+	}), runs
+}
+
+func startSchedule(schedule Schedule, check checks.Checker, clock *fakeClock) Updater {
+	schedule.now = clock.now
+	schedule.after = clock.after
+	return ScheduledThresholdChecker(check, schedule, NewThresholdStatusUpdater(1)).(Updater)
+}
+
+func TestScheduleWaitsForFirstPeriod(t *testing.T) {
+	clock := newFakeClock()
+	check, runs := scheduledCheck(nil)
+	startSchedule(Schedule{Period: 10 * time.Second}, check, clock)
+
+	assert.Equal(t, 10*time.Second, <-clock.waits)
+	assert.Len(t, runs, 0)
+	clock.advance(10 * time.Second)
+	assert.NoError(t, <-runs)
+	assert.Equal(t, 10*time.Second, <-clock.waits)
+}
+
+func TestScheduleRunsImmediately(t *testing.T) {
+	clock := newFakeClock()
+	check, runs := scheduledCheck(nil)
+	startSchedule(Schedule{Period: 10 * time.Second, Immediate: true}, check, clock)
+
+	assert.Equal(t, time.Duration(0), <-clock.waits)
+	assert.NoError(t, <-runs)
+	assert.Equal(t, 10*time.Second, <-clock.waits)
+}
+
+func TestScheduleSplay(t *testing.T) {
+	clock := newFakeClock()
+	check, runs := scheduledCheck(nil)
+	splays := []int64{int64(3 * time.Second), int64(time.Second)}
+	schedule := Schedule{Period: 10 * time.Second, Splay: 5 * time.Second, random: func(n int64) int64 {
+		require.Equal(t, int64(5*time.Second), n)
+		splay := splays[0]
+		splays = splays[1:]
+		return splay
+	}}
+	startSchedule(schedule, check, clock)
+
+	assert.Equal(t, 13*time.Second, <-clock.waits)
+	clock.advance(13 * time.Second)
+	<-runs
+	// the splay does not accumulate, the next run is splayed from 20s
+	assert.Equal(t, 8*time.Second, <-clock.waits)
+}
+
+func TestScheduleFailureFrequency(t *testing.T) {
+	clock := newFakeClock()
+	down := errors.New("down")
+	check, runs := scheduledCheck(nil, down, down, nil)
+	updater := startSchedule(Schedule{Period: 30 * time.Second, FailurePeriod: 5 * time.Second, Immediate: true}, check, clock)
+
+	<-clock.waits
+	assert.NoError(t, <-runs)
+	assert.Equal(t, 30*time.Second, <-clock.waits)
+
+	clock.advance(30 * time.Second)
+	assert.Error(t, <-runs)
+	assert.Equal(t, 5*time.Second, <-clock.waits)
+	assert.EqualError(t, updater.Check(), "down")
+
+	clock.advance(5 * time.Second)
+	assert.Error(t, <-runs)
+	assert.Equal(t, 5*time.Second, <-clock.waits)
+
+	clock.advance(5 * time.Second)
+	assert.NoError(t, <-runs)
+	assert.Equal(t, 30*time.Second, <-clock.waits)
+	assert.Nil(t, updater.Check())
+}
+
+func TestScheduleDropsMissedRuns(t *testing.T) {
+	clock := newFakeClock()
+	slow := checks.CheckFunc(func() error {
+		clock.advance(25 * time.Second)
+		return nil
+	})
+	startSchedule(Schedule{Period: 10 * time.Second, Immediate: true}, slow, clock)
+
+	<-clock.waits
+	// the run took 25s, so the runs at 10s and 20s are dropped
+	assert.Equal(t, 5*time.Second, <-clock.waits)
+}
+
+func TestScheduleCountsSkippedRuns(t *testing.T) {
+	clock := newFakeClock()
+	slow := checks.CheckFunc(func() error {
+		clock.advance(25 * time.Second)
+		return nil
+	})
+	runs := &Runs{}
+	startSchedule(Schedule{Period: 10 * time.Second, Immediate: true, Runs: runs}, slow, clock)
+
+	<-clock.waits
+	<-clock.waits
+	assert.Equal(t, int64(2), runs.Skipped())
+}
+
+func TestScheduleDeadline(t *testing.T) {
+	cancelled := make(chan error, 1)
+	check := checks.ContextCheckFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return errors.New("request cancelled")
+	})
+	runs := &Runs{}
+	updater := ScheduledThresholdChecker(check, Schedule{Period: time.Hour, Immediate: true, Deadline: 20 * time.Millisecond, Runs: runs}, NewThresholdStatusUpdater(1))
+
+	assert.Equal(t, context.DeadlineExceeded, <-cancelled)
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, checks.IsTimeout(updater.Check()))
+	assert.EqualError(t, updater.Check(), "timed out after 20ms")
+	assert.Equal(t, int64(1), runs.TimedOut())
+}
+
+func TestScheduleDoesNotOverlapTimedOutRuns(t *testing.T) {
+	clock := newFakeClock()
+	release := make(chan bool)
+	var calls int32
+	hung := checks.CheckFunc(func() error {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return nil
+	})
+	runs := &Runs{}
+	updater := startSchedule(Schedule{Period: 10 * time.Second, Immediate: true, Deadline: 10 * time.Millisecond, Runs: runs}, hung, clock)
+
+	<-clock.waits
+	assert.Equal(t, 10*time.Second, <-clock.waits)
+	assert.True(t, checks.IsTimeout(updater.Check()))
+
+	// the first run has not returned, so the next is skipped
+	clock.advance(10 * time.Second)
+	<-clock.waits
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, int64(1), runs.Skipped())
+
+	release <- true
+	time.Sleep(10 * time.Millisecond)
+	clock.advance(10 * time.Second)
+	<-clock.waits
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	close(release)
+}
@@ -0,0 +1,71 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// startupUpdater wraps an Updater for a check that needs time to start. The
+// check is not judged until it has either passed once, or the startup timeout
+// has expired. Failures before the first pass are not passed on to the
+// wrapped updater; after it, the wrapped updater's thresholds apply.
+type startupUpdater struct {
+	mu       sync.Mutex
+	updater  Updater
+	timeout  time.Duration
+	deadline time.Time
+	now      func() time.Time
+	passed   bool
+	status   error
+}
+
+// NewStartupStatusUpdater returns an Updater that is healthy until the check
+// first passes, or fails if it has not passed within timeout
+func NewStartupStatusUpdater(updater Updater, timeout time.Duration) Updater {
+	return &startupUpdater{updater: updater, timeout: timeout, deadline: time.Now().Add(timeout), now: time.Now}
+}
+
+// Check implements the Checker interface
+func (su *startupUpdater) Check() error {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	if su.passed {
+		return su.updater.Check()
+	}
+	if su.now().Before(su.deadline) {
+		return nil
+	}
+	if su.status != nil {
+		return carrySeverity(su.status, fmt.Errorf("not started within %s: %v", su.timeout, su.status))
+	}
+	return fmt.Errorf("not started within %s", su.timeout)
+}
+
+// Update implements the Updater interface
+func (su *startupUpdater) Update(status error) {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	if !su.passed && status != nil {
+		su.status = status
+		return
+	}
+	su.passed = true
+	su.updater.Update(status)
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStartupUpdater(clock *fakeTime, timeout time.Duration) *startupUpdater {
+	su := NewStartupStatusUpdater(NewThresholdStatusUpdater(2), timeout).(*startupUpdater)
+	su.now = clock.now
+	su.deadline = clock.now().Add(timeout)
+	return su
+}
+
+func TestStartupFailuresAreIgnoredUntilPassed(t *testing.T) {
+	failure := errors.New("connection refused")
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	su := newTestStartupUpdater(clock, 4*time.Minute)
+
+	for i := 0; i < 10; i++ {
+		su.Update(failure)
+		clock.advance(10 * time.Second)
+	}
+	assert.Nil(t, su.Check())
+
+	su.Update(nil)
+	su.Update(failure)
+	assert.Nil(t, su.Check())
+	su.Update(failure)
+	assert.Equal(t, failure, su.Check())
+}
+
+func TestStartupTimeoutCountsAsFailure(t *testing.T) {
+	failure := errors.New("connection refused")
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	su := newTestStartupUpdater(clock, 2*time.Second)
+
+	su.Update(failure)
+	assert.Nil(t, su.Check())
+	clock.advance(2 * time.Second)
+	assert.EqualError(t, su.Check(), "not started within 2s: connection refused")
+
+	su.Update(nil)
+	assert.Nil(t, su.Check())
+}
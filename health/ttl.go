@@ -0,0 +1,60 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package health
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ttlUpdater wraps an Updater for a passive check, where the status is pushed
+// by the application rather than checked periodically. If the status has not
+// been updated within the ttl, the check fails.
+type ttlUpdater struct {
+	mu      sync.Mutex
+	updater Updater
+	ttl     time.Duration
+	now     func() time.Time
+	updated time.Time
+}
+
+// NewTTLStatusUpdater returns an Updater that fails if it has not been
+// updated within the ttl, and otherwise has the status of the wrapped
+// updater. The ttl starts from when it is created, so a check that never
+// receives an update fails.
+func NewTTLStatusUpdater(updater Updater, ttl time.Duration) Updater {
+	return &ttlUpdater{updater: updater, ttl: ttl, now: time.Now, updated: time.Now()}
+}
+
+// Check implements the Checker interface
+func (tu *ttlUpdater) Check() error {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	if since := tu.now().Sub(tu.updated); since > tu.ttl {
+		return fmt.Errorf("no update received for %s, ttl is %s", since.Truncate(time.Second), tu.ttl)
+	}
+	return tu.updater.Check()
+}
+
+// Update implements the Updater interface
+func (tu *ttlUpdater) Update(status error) {
+	tu.mu.Lock()
+	defer tu.mu.Unlock()
+	tu.updated = tu.now()
+	tu.updater.Update(status)
+}
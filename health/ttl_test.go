@@ -0,0 +1,33 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLFailsWithoutUpdates(t *testing.T) {
+	clock := &fakeTime{current: time.Unix(1000, 0)}
+	tu := NewTTLStatusUpdater(NewStatusUpdater(), time.Minute).(*ttlUpdater)
+	tu.now = clock.now
+	tu.updated = clock.now()
+
+	assert.Nil(t, tu.Check())
+	clock.advance(61 * time.Second)
+	assert.Error(t, tu.Check())
+
+	tu.Update(nil)
+	assert.Nil(t, tu.Check())
+
+	tu.Update(errors.New("queue backed up"))
+	assert.EqualError(t, tu.Check(), "queue backed up")
+
+	clock.advance(30 * time.Second)
+	tu.Update(nil)
+	clock.advance(45 * time.Second)
+	assert.Nil(t, tu.Check())
+	clock.advance(30 * time.Second)
+	assert.Contains(t, tu.Check().Error(), "no update received")
+}
@@ -17,13 +17,22 @@
 package main
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"math"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
+	"sort"
 	"strings"
-	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -32,14 +41,17 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/cloudfoundry/gosigar"
 	"github.com/robfig/cron"
 	"github.com/takama/daemon"
 	"github.com/tevino/abool"
+	"github.com/tootedom/ec2-local-healthchecker/actions"
+	"github.com/tootedom/ec2-local-healthchecker/blackout"
 	"github.com/tootedom/ec2-local-healthchecker/checks"
 	"github.com/tootedom/ec2-local-healthchecker/config"
 	"github.com/tootedom/ec2-local-healthchecker/health"
+	"github.com/tootedom/ec2-local-healthchecker/maintenance"
+	"github.com/tootedom/ec2-local-healthchecker/policy"
 )
 
 const (
@@ -48,12 +60,6 @@ const (
 	description = "ec2-local-healthchecker"
 )
 
-type EnvData struct {
-	region     string
-	instanceId string
-	creds      *credentials.Credentials
-}
-
 type UptimeCalc func() int64
 
 var stdlog, errlog *log.Logger
@@ -64,74 +70,226 @@ type Service struct {
 }
 
 func registerInstanceAsUnhealthy() {
-	env := globalEnvData.Load().(EnvData)
-	regionName := env.region
-	instanceID := env.instanceId
-	creds := env.creds
 	if instanceIsHealthy.IsSet() {
-		sess, err := session.NewSession(&aws.Config{Credentials: creds, Region: aws.String(regionName)})
-		if err == nil {
-			asg := autoscaling.New(sess, aws.NewConfig().WithRegion(regionName))
-			input := autoscaling.SetInstanceHealthInput{HealthStatus: aws.String("Unhealthy"), InstanceId: aws.String(instanceID)}
-			_, err := asg.SetInstanceHealth(&input)
+		if err := instanceAction.SetUnhealthy(); actions.IsSkipped(err) {
+			errlog.Println("Not marking instance as Unhealthy,", err)
+		} else if err != nil {
+			errlog.Println("Unable to set instance as Unhealthy:", err)
+		} else {
+			errlog.Println("Marked Instance as Unhealthy")
+			instanceIsHealthy.UnSet()
+		}
+	}
+}
 
-			if err != nil {
-				errlog.Printf("Unable to set instance(%s) as Unhealthy: %v", instanceID, err)
-				errlog.Println()
-			} else {
-				errlog.Println("Marked Instance as Unhealthy")
-				instanceIsHealthy.UnSet()
-			}
+func registerInstanceAsHealthy() {
+	if !instanceIsHealthy.IsSet() {
+		if err := instanceAction.SetHealthy(); actions.IsSkipped(err) {
+			errlog.Println("Not marking instance as Healthy,", err)
+		} else if err != nil {
+			errlog.Println("Unable to set instance as Healthy:", err)
 		} else {
-			errlog.Println("Unable to create a AWS Session", err)
+			errlog.Println("Marked Instance as Healthy")
+			instanceIsHealthy.Set()
 		}
+	}
+}
 
+func logWarnings() {
+	for checkName, warning := range defaultRegistry.WarningStatus() {
+		errlog.Printf("Health check warning: %s: %s", checkName, warning)
+	}
+	for checkName, blocked := range defaultRegistry.BlockedStatus() {
+		errlog.Printf("Health check skipped: %s: %s", checkName, blocked)
 	}
 }
 
-func registerInstanceAsHealthy() {
-	env := globalEnvData.Load().(EnvData)
-	regionName := env.region
-	instanceID := env.instanceId
-	creds := env.creds
+// checkStates returns whether each check is passing, for evaluating the
+// policy. A check reporting a warning is passing.
+func checkStates() map[string]bool {
+	ignored := blackouts.IgnoredChecks(time.Now())
+	states := make(map[string]bool)
+	for checkName, err := range defaultRegistry.Statuses() {
+		_, isIgnored := ignored[checkName]
+		states[checkName] = err == nil || checks.IsWarning(err) || isIgnored
+	}
+	return states
+}
 
-	if !instanceIsHealthy.IsSet() {
-		sess, err := session.NewSession(&aws.Config{Credentials: creds, Region: aws.String(regionName)})
-		if err == nil {
-			asg := autoscaling.New(sess, aws.NewConfig().WithRegion(regionName))
-			input := autoscaling.SetInstanceHealthInput{HealthStatus: aws.String("Healthy"), InstanceId: aws.String(instanceID)}
-			_, err := asg.SetInstanceHealth(&input)
+// unhealthyChecks returns the checks that make the instance unhealthy. Without
+// a policy that is every failing critical check; with a policy it is every
+// failing check when the policy is not satisfied. Checks ignored by an open
+// blackout window are passing.
+func unhealthyChecks() map[string]string {
+	ignored := blackouts.IgnoredChecks(time.Now())
+	if instancePolicy == nil {
+		unhealthy := defaultRegistry.CheckStatus()
+		for checkName := range ignored {
+			delete(unhealthy, checkName)
+		}
+		return unhealthy
+	}
+	unhealthy := make(map[string]string)
+	if instancePolicy.Evaluate(checkStates()) {
+		return unhealthy
+	}
+	for checkName, err := range defaultRegistry.Statuses() {
+		if _, isIgnored := ignored[checkName]; err != nil && !health.IsBlocked(err) && !isIgnored {
+			unhealthy[checkName] = err.Error()
+		}
+	}
+	unhealthy["policy"] = "not satisfied: " + instancePolicy.String()
+	return unhealthy
+}
 
-			if err != nil {
-				errlog.Printf("Unable to set instance(%s) as Healthy: %v", instanceID, err)
-				errlog.Println()
-			} else {
-				errlog.Println("Marked Instance as Healthy")
-				instanceIsHealthy.Set()
-			}
+// ExplainHealth describes the current state of each check, and how the
+// policy evaluates against them. It returns true if the instance is healthy.
+func ExplainHealth() (string, bool) {
+	var buf bytes.Buffer
+	statuses := defaultRegistry.Statuses()
+	checkNames := make([]string, 0, len(statuses))
+	for checkName := range statuses {
+		checkNames = append(checkNames, checkName)
+	}
+	sort.Strings(checkNames)
+
+	ignored := blackouts.IgnoredChecks(time.Now())
+	buf.WriteString("Checks:\n")
+	for _, checkName := range checkNames {
+		if window, isIgnored := ignored[checkName]; isIgnored {
+			fmt.Fprintf(&buf, "  IGNORE  %s: in blackout window %s\n", checkName, window)
+		} else if err := statuses[checkName]; err != nil {
+			fmt.Fprintf(&buf, "  %s  %s: %v\n", strings.ToUpper(checkResult(err)), checkName, err)
 		} else {
-			errlog.Println("Unable to create a AWS Session", err)
+			fmt.Fprintf(&buf, "  PASS  %s\n", checkName)
+		}
+	}
+
+	buf.WriteString("Policy:\n")
+	if instancePolicy == nil {
+		buf.WriteString("  all critical checks must pass\n")
+	} else {
+		for _, line := range strings.Split(strings.TrimRight(instancePolicy.Explain(checkStates()), "\n"), "\n") {
+			buf.WriteString("  " + line + "\n")
 		}
 	}
+
+	healthy := len(unhealthyChecks()) == 0
+	if healthy {
+		buf.WriteString("Instance is healthy\n")
+	} else {
+		buf.WriteString("Instance is unhealthy\n")
+	}
+	return buf.String(), healthy
 }
 
 func checkChecks() {
-	if len(defaultRegistry.CheckStatus()) > 0 {
+	logWarnings()
+	unhealthy := len(unhealthyChecks()) > 0
+	if unhealthy {
 		errlog.Println("Health check failure")
-		registerInstanceAsUnhealthy()
 	} else {
 		errlog.Println("Health check success")
+	}
+
+	if maintenanceMode != nil {
+		if state := maintenanceMode.State(); state.Active {
+			errlog.Printf("Not taking action, %s", state)
+			return
+		}
+	}
+	if window, suppressed := blackouts.SuppressingActions(time.Now()); suppressed {
+		errlog.Printf("Not taking action, in blackout window %s", window)
+		return
+	}
+	if unhealthy {
+		registerInstanceAsUnhealthy()
+	} else {
 		registerInstanceAsHealthy()
 	}
 }
 
+// CreateMaintenance creates the maintenance mode manager, logging an event
+// when maintenance mode starts or ends
+func CreateMaintenance(conf config.Config) *maintenance.Manager {
+	manager := maintenance.NewManager(conf.Maintenance.File, conf.Maintenance.Duration)
+	manager.OnChange(func(state maintenance.State) {
+		if state.Active {
+			errlog.Printf("Maintenance mode started: %s", state)
+		} else {
+			errlog.Println("Maintenance mode ended")
+		}
+	})
+	return manager
+}
+
+// CreateBlackouts creates the configured blackout windows
+func CreateBlackouts(conf config.Config) blackout.Windows {
+	windows := make(blackout.Windows, 0, len(conf.Blackouts))
+	for name, b := range conf.Blackouts {
+		schedule, err := config.ParseSchedule(b.Schedule)
+		if err != nil {
+			errlog.Printf("Ignoring blackout %s: %v", name, err)
+			continue
+		}
+		location, err := b.Location()
+		if err != nil {
+			errlog.Printf("Ignoring blackout %s: %v", name, err)
+			continue
+		}
+		windows = append(windows, blackout.Window{
+			Name:     name,
+			Schedule: schedule,
+			Duration: b.Duration,
+			Location: location,
+			Checks:   b.Checks,
+		})
+	}
+	return windows
+}
+
+// handleMaintenanceSignals starts maintenance mode for the default duration
+// on SIGUSR1, and ends it on SIGUSR2
+func handleMaintenanceSignals(manager *maintenance.Manager) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				manager.Start(0, "started by signal", "signal")
+			} else if err := manager.End(); err != nil {
+				errlog.Println("Unable to end maintenance mode: ", err)
+			}
+		}
+	}()
+}
+
+// MaintenanceCommand runs the maintenance-start and maintenance-end admin
+// commands, which write or remove the maintenance flag file
+func MaintenanceCommand(conf config.Config, command string, duration time.Duration, reason string) (string, error) {
+	if command == "maintenance-end" {
+		if err := os.Remove(conf.Maintenance.File); err != nil && !os.IsNotExist(err) {
+			return "Unable to end maintenance mode", err
+		}
+		return "Maintenance mode ended", nil
+	}
+	if duration <= 0 {
+		duration = conf.Maintenance.Duration
+	}
+	until := time.Now().Add(duration)
+	if err := maintenance.WriteFile(conf.Maintenance.File, until, reason); err != nil {
+		return "Unable to start maintenance mode", err
+	}
+	return fmt.Sprintf("Maintenance mode started until %s", until.Format(time.RFC3339)), nil
+}
+
 func WaitForGracePeriod(gracePeriod time.Duration, uptime UptimeCalc, checkHealthy bool) bool {
 	for {
 		if int64(gracePeriod.Seconds()) < uptime() {
 			return false
 		}
 		time.Sleep(5 * time.Second)
-		if checkHealthy && len(defaultRegistry.CheckStatus()) == 0 {
+		if checkHealthy && len(unhealthyChecks()) == 0 {
 			return true
 		}
 	}
@@ -174,6 +332,10 @@ func (service *Service) Manage(conf config.Config, command string, uptimeCalcula
 	signal.Notify(interrupt, os.Interrupt, os.Kill, syscall.SIGTERM)
 
 	CreateChecks(conf)
+	handleMaintenanceSignals(maintenanceMode)
+	if err := StartServer(conf); err != nil {
+		return "Unable to start local endpoint", err
+	}
 	// Create a new cron manager
 	c := cron.New()
 	// Run makefile every min
@@ -185,24 +347,273 @@ func (service *Service) Manage(conf config.Config, command string, uptimeCalcula
 	return "Service exited", nil
 }
 
-var globalEnvData atomic.Value
+var instanceAction actions.Action
 
 var defaultRegistry *health.Registry
+var instancePolicy *policy.Policy
+var passiveChecks map[string]health.Updater
+var checkRuns map[string]*health.Runs
+var maintenanceMode *maintenance.Manager
+var blackouts blackout.Windows
 var instanceIsHealthy *abool.AtomicBool
 var gracePeriodOver *abool.AtomicBool
 
 func CreateChecks(conf config.Config) {
 	defaultRegistry = health.NewRegistry()
+	instancePolicy = nil
+	maintenanceMode = CreateMaintenance(conf)
+	blackouts = CreateBlackouts(conf)
+	if conf.Policy != "" {
+		p, err := policy.Parse(conf.Policy)
+		if err != nil {
+			errlog.Println("Error Parsing Policy: ", err)
+			os.Exit(1)
+		}
+		instancePolicy = p
+	}
+	passiveChecks = make(map[string]health.Updater)
+	checkRuns = make(map[string]*health.Runs)
 	for checkName, check := range conf.Checks {
-		var checker checks.Checker
-		if strings.ToLower(check.Type) == "tcp" {
-			checker = checks.TCPChecker(check.Endpoint, check.Timeout)
-		} else {
-			checker = checks.HTTPChecker(check.Endpoint, 200, check.Timeout, nil)
+		updater := CreateUpdater(check)
+		if check.Flap.Changes > 0 {
+			hold := health.FlapHoldFailed
+			if check.Flap.Hold != "" {
+				hold = health.FlapHold(check.Flap.Hold)
+			}
+			updater = health.NewFlapDetector(updater, check.Flap.Window, check.Flap.Changes, hold)
+		}
+		if check.Startup.Timeout > 0 && strings.ToLower(check.Type) != "passive" {
+			updater = health.NewStartupStatusUpdater(updater, check.Startup.Timeout)
+		}
+
+		severity := health.SeverityCritical
+		if check.Severity == "warning" {
+			severity = health.SeverityWarning
+		}
+
+		if strings.ToLower(check.Type) == "passive" {
+			passive := health.NewTTLStatusUpdater(updater, check.TTL)
+			if check.Startup.Timeout > 0 {
+				passive = health.NewStartupStatusUpdater(passive, check.Startup.Timeout)
+			}
+			passiveChecks[checkName] = passive
+			defaultRegistry.RegisterWithSeverity(checkName, passive, severity)
+			continue
+		}
+
+		schedule := health.Schedule{
+			Period:        check.Frequency,
+			Immediate:     check.Immediate,
+			Splay:         check.Splay,
+			FailurePeriod: check.FailureFrequency,
+			Deadline:      check.RunDeadline(),
+			Runs:          &health.Runs{},
+		}
+		checkRuns[checkName] = schedule.Runs
+		if check.Schedule != "" {
+			var err error
+			if schedule.Cron, err = config.ParseSchedule(check.Schedule); err != nil {
+				errlog.Printf("Invalid schedule for %s: %v", checkName, err)
+				continue
+			}
+		}
+		if check.Pause && len(check.DependsOn) > 0 {
+			registry, name := defaultRegistry, checkName
+			schedule.Paused = func() bool {
+				return registry.Blocked(name)
+			}
+		}
+
+		defaultRegistry.RegisterWithSeverity(checkName, health.ScheduledThresholdChecker(CreateChecker(check), schedule, updater), severity)
+	}
+
+	for checkName, check := range conf.Checks {
+		if len(check.DependsOn) > 0 {
+			defaultRegistry.DependsOn(checkName, check.DependsOn...)
+		}
+	}
+}
+
+// CreateChecker returns the Checker that runs the check
+func CreateChecker(check config.Check) checks.Checker {
+	checkType := strings.ToLower(check.Type)
+	switch checkType {
+	case "tcp":
+		if check.Send == "" && check.Expect == "" && check.ExpectPattern == "" && !check.TLS.Enabled {
+			return checks.TCPChecker(check.Endpoint, check.Timeout)
+		}
+		tlsConfig, err := CreateTLSConfig(check.TLS, check.Endpoint)
+		if err != nil {
+			return checks.CheckFunc(func() error { return err })
+		}
+		send, _ := checks.Unescape(check.Send)
+		return checks.TCPExpectChecker(check.Endpoint, send, CreateExpect(check), tlsConfig, check.Timeout)
+	case "exec":
+		return checks.ExecChecker(check.Command, check.Timeout)
+	case "disk":
+		return checks.DiskChecker(check.Path, checks.DiskThresholds{
+			MaxUsedPercent: check.Disk.UsedPercent,
+			MinFreeBytes:   uint64(check.Disk.Free),
+			MinFreeInodes:  check.Disk.FreeInodes,
+		})
+	case "memory":
+		return checks.MemoryChecker(checks.MemoryThresholds{
+			MinAvailableBytes:   uint64(check.Memory.Available),
+			MinAvailablePercent: check.Memory.AvailablePercent,
+			MaxSwapUsedBytes:    uint64(check.Memory.SwapUsed),
+			MaxSwapUsedPercent:  check.Memory.SwapUsedPercent,
+		})
+	case "load":
+		return checks.LoadChecker(checks.LoadThresholds{
+			MaxOne:     check.Load.One,
+			MaxFive:    check.Load.Five,
+			MaxFifteen: check.Load.Fifteen,
+		})
+	case "process":
+		return checks.ProcessChecker(CreateProcessMatch(check.Process), check.Process.MinCount(), check.Process.Max, check.Process.FailOnRestart)
+	case "dns":
+		return checks.DNSChecker(check.Endpoint, check.DNS.Name, check.DNS.Type, check.DNS.Values, check.Timeout)
+	case "udp":
+		send, _ := checks.Unescape(check.Send)
+		return checks.UDPChecker(check.Endpoint, send, CreateExpect(check), check.Timeout)
+	case "memcached", "redis":
+		tlsConfig, err := CreateTLSConfig(check.TLS, check.Endpoint)
+		if err != nil {
+			return checks.CheckFunc(func() error { return err })
+		}
+		if checkType == "memcached" {
+			return checks.MemcachedChecker(check.Endpoint, tlsConfig, CreateMemcachedOptions(check.Memcached), check.Timeout)
+		}
+		password, err := check.Credentials.ReadPassword()
+		if err != nil {
+			return checks.CheckFunc(func() error { return err })
+		}
+		return checks.RedisChecker(check.Endpoint, tlsConfig, checks.RedisOptions{
+			Username:    check.Credentials.Username,
+			Password:    password,
+			Role:        check.Redis.Role,
+			MinReplicas: check.Redis.MinReplicas,
+			MaxLastIO:   check.Redis.MaxLastIO,
+		}, check.Timeout)
+	case "postgres", "mysql":
+		password, err := check.Credentials.ReadPassword()
+		if err != nil {
+			return checks.CheckFunc(func() error { return err })
+		}
+		options := checks.SQLOptions{
+			Database: check.SQL.Database,
+			Username: check.Credentials.Username,
+			Password: password,
+			Params:   check.SQL.Params,
+			Query:    check.SQL.Query,
+			Min:      check.SQL.Min,
+			Max:      check.SQL.Max,
+		}
+		if checkType == "postgres" {
+			return checks.PostgresChecker(check.Endpoint, options, check.Timeout)
+		}
+		return checks.MySQLChecker(check.Endpoint, options, check.Timeout)
+	case "grpc":
+		tlsConfig, err := CreateTLSConfig(check.TLS, check.Endpoint)
+		if err != nil {
+			return checks.CheckFunc(func() error { return err })
+		}
+		return checks.GRPCChecker(check.Endpoint, check.GRPC.Service, tlsConfig, check.GRPC.Metadata, check.Timeout)
+	case "process_resources":
+		return checks.ProcessResourceChecker(CreateProcessMatch(check.Process), checks.ProcessResourceThresholds{
+			MaxRSSBytes:       uint64(check.Resources.RSS),
+			MaxRSSPercent:     check.Resources.RSSPercent,
+			MaxCPUPercent:     check.Resources.CPUPercent,
+			MaxFDs:            check.Resources.FDs,
+			MaxFDsPercent:     check.Resources.FDsPercent,
+			MaxThreads:        check.Resources.Threads,
+			MaxThreadsPercent: check.Resources.ThreadsPercent,
+		})
+	}
+	var headers http.Header
+	if check.Host != "" {
+		headers = http.Header{"Host": {check.Host}}
+	}
+	if strings.HasPrefix(check.Endpoint, "unix://") {
+		path := check.Path
+		if path == "" {
+			path = "/"
+		}
+		return checks.HTTPUnixChecker(strings.TrimPrefix(check.Endpoint, "unix://"), path, 200, check.Timeout, headers)
+	}
+	return checks.HTTPChecker(check.Endpoint, 200, check.Timeout, headers)
+}
+
+// CreateTLSConfig returns the TLS configuration for a check connecting to the
+// endpoint, or nil if TLS is not enabled
+func CreateTLSConfig(conf config.TLS, endpoint string) (*tls.Config, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{ServerName: conf.ServerName, InsecureSkipVerify: conf.InsecureSkipVerify}
+	if tlsConfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(endpoint); err == nil {
+			tlsConfig.ServerName = host
+		}
+	}
+	if conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, err
 		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in " + conf.CAFile)
+		}
+	}
+	return tlsConfig, nil
+}
 
-		defaultRegistry.Register(checkName, health.PeriodicThresholdChecker(checker, check.Frequency, check.Threshold))
+// CreateMemcachedOptions returns the options of a memcached check. Evictions
+// are only checked if a threshold is configured.
+func CreateMemcachedOptions(conf config.Memcached) checks.MemcachedOptions {
+	options := checks.MemcachedOptions{
+		MaxEvictions:          -1,
+		MaxConnectionsPercent: conf.ConnectionsPercent,
+		CanaryKey:             conf.Canary,
+	}
+	if conf.Evictions != nil {
+		options.MaxEvictions = *conf.Evictions
 	}
+	return options
+}
+
+// CreateExpect returns the response expected by a send and expect check
+func CreateExpect(check config.Check) checks.Expect {
+	expect := checks.Expect{}
+	expect.Literal, _ = checks.Unescape(check.Expect)
+	if check.ExpectPattern != "" {
+		expect.Pattern = regexp.MustCompile(check.ExpectPattern)
+	}
+	return expect
+}
+
+// CreateProcessMatch returns the match for the processes of a process check
+func CreateProcessMatch(process config.Process) checks.ProcessMatch {
+	match := checks.ProcessMatch{PidFile: process.PidFile, Name: process.Name}
+	if process.Cmdline != "" {
+		match.Cmdline = regexp.MustCompile(process.Cmdline)
+	}
+	return match
+}
+
+// CreateUpdater returns the Updater that evaluates the results of the check
+func CreateUpdater(check config.Check) health.Updater {
+	switch check.Evaluator.Type {
+	case "ratio":
+		return health.NewRatioStatusUpdater(check.Evaluator.Samples, check.Evaluator.Ratio)
+	case "duration":
+		return health.NewDurationStatusUpdater(check.Evaluator.Duration)
+	}
+	if check.RiseCount() == 0 && check.FallCount() == 0 {
+		return health.NewStatusUpdater()
+	}
+	return health.NewRiseFallStatusUpdater(check.RiseCount(), check.FallCount())
 }
 
 func init() {
@@ -231,32 +642,31 @@ func CreateUpdateCalculationFunction(launchtime int64, gracePeriod time.Duration
 
 }
 
-func CalculateMaxCheckWaitTime(checks map[string]config.Check) int {
-	maxTime := 0
-	for _, check := range checks {
-		seconds := int(check.Frequency.Seconds()) * int(check.Threshold)
-		if seconds > maxTime {
-			maxTime = seconds
-		}
+// CreateLocalAction returns the action to use when running standalone. Only
+// local actions can be used; if none is configured the result of the checks
+// is only logged.
+func CreateLocalAction(conf config.Action) actions.Action {
+	switch conf.Type {
+	case "exec":
+		return actions.ExecAction(conf.Command, conf.Timeout)
+	case "file":
+		return actions.StatusFileAction(conf.Path)
+	case "asg":
+		errlog.Println("AutoScaling action cannot be used in standalone mode")
+		os.Exit(1)
 	}
-	return maxTime
+	return actions.NoopAction()
 }
 
-func main() {
-	instanceIsHealthy = abool.NewBool(true)
-	gracePeriodOver = abool.NewBool(false)
-
-	checkfilePtr := flag.String("healthcheckfile", "/etc/sysconfig/ec2-local-healthchecker.yml", "The location of healthchecks yaml file")
-	testConfigPtr := flag.Bool("testconfig", false, "test the healthcheck file is parseable")
-	foregroundPtr := flag.Bool("foreground", false, "run the healthchecks in the foreground, exiting with nonzero if checks fail")
-	exitForegroundIfHealthlyPtr := flag.Bool("fg-exit-early-if-healthy", false, "When running in the foreground can exit early before graceperiod is over if health checks are ok")
-	launchTime := flag.Int64("launchtime", -1, "The launch time of the server that is running")
-	commandPtr := flag.String("command", "", "The command to run")
-
-	flag.Parse()
-	runInForeground := *foregroundPtr
-	exitEarlyForHealthyStatus := *exitForegroundIfHealthlyPtr
-	checkfile := *checkfilePtr
+// CreateAWSAction returns the action to use when running on ec2. Unless a
+// local action is configured the instance health is set in the AutoScaling
+// group, which requires the instance id, region and credentials.
+// If configured, the grace period is replaced with the group's
+// HealthCheckGracePeriod, and the launch time of the instance is returned.
+func CreateAWSAction(conf *config.Config) (actions.Action, time.Time) {
+	if conf.Action.Type == "exec" || conf.Action.Type == "file" {
+		return CreateLocalAction(conf.Action), time.Time{}
+	}
 
 	sess := session.Must(session.NewSession(&aws.Config{}))
 	svc := ec2metadata.New(sess)
@@ -283,9 +693,105 @@ func main() {
 			},
 		})
 
-	env := EnvData{region: region, instanceId: instanceID, creds: creds}
+	asg := actions.ASGAction(region, instanceID, creds)
+	asg.RespectGracePeriod = conf.AutoScaling.RespectGracePeriod
+	asg.Logger = errlog
+	asg.TargetLifecycleState = func() (string, error) {
+		return svc.GetMetadata("autoscaling/target-lifecycle-state")
+	}
+
+	if conf.AutoScaling.GracePeriodFromGroup {
+		gracePeriod, err := asg.GracePeriod()
+		if err == nil {
+			stdlog.Printf("Using the AutoScaling group grace period of %s", gracePeriod)
+			conf.GracePeriod = gracePeriod
+		} else {
+			errlog.Printf("Unable to obtain the AutoScaling group grace period, using %s: %v", conf.GracePeriod, err)
+		}
+	}
+
+	var launched time.Time
+	if conf.AutoScaling.LaunchTimeFromInstance {
+		var err error
+		launched, err = asg.LaunchTime()
+		if err != nil {
+			errlog.Println("Unable to describe the instance launch time, using the instance identity document: ", err)
+			doc, err := svc.GetInstanceIdentityDocument()
+			if err == nil {
+				launched = doc.PendingTime
+			} else {
+				errlog.Println("Unable to obtain the instance launch time, using uptime: ", err)
+			}
+		}
+	}
 
-	globalEnvData.Store(env)
+	return asg, launched
+}
+
+// CreateLaunchTimeUptimeFunction returns an UptimeCalc measuring from the
+// launch time of the instance. Unlike host uptime it is not reset by a
+// reboot, or by restarting the daemon.
+func CreateLaunchTimeUptimeFunction(launched time.Time) UptimeCalc {
+	return func() int64 {
+		return int64(time.Since(launched).Seconds())
+	}
+}
+
+func CalculateMaxCheckWaitTime(checks map[string]config.Check) int {
+	maxTime := 0
+	for _, check := range checks {
+		interval := check.Interval()
+		// after the first failure, a check with a failure frequency is run
+		// more often
+		failureInterval := interval
+		if check.FailureFrequency > 0 {
+			failureInterval = check.FailureFrequency
+		}
+		failuresFor := func(failures int) int {
+			if failures <= 0 {
+				return 0
+			}
+			return int((interval + failureInterval*time.Duration(failures-1) + check.Splay).Seconds())
+		}
+		seconds := failuresFor(check.FallCount())
+		switch check.Evaluator.Type {
+		case "ratio":
+			seconds = failuresFor(int(math.Ceil(check.Evaluator.Ratio * float64(check.Evaluator.Samples))))
+		case "duration":
+			seconds = int((check.Evaluator.Duration + interval + check.Splay).Seconds())
+		}
+		if strings.ToLower(check.Type) == "passive" {
+			seconds = int(check.TTL.Seconds())
+		}
+		if startup := int(check.Startup.Timeout.Seconds()); startup > seconds {
+			seconds = startup
+		}
+		if seconds > maxTime {
+			maxTime = seconds
+		}
+	}
+	return maxTime
+}
+
+func main() {
+	instanceIsHealthy = abool.NewBool(true)
+	gracePeriodOver = abool.NewBool(false)
+
+	checkfilePtr := flag.String("healthcheckfile", "/etc/sysconfig/ec2-local-healthchecker.yml", "The location of healthchecks yaml file")
+	testConfigPtr := flag.Bool("testconfig", false, "test the healthcheck file is parseable")
+	foregroundPtr := flag.Bool("foreground", false, "run the healthchecks in the foreground, exiting with nonzero if checks fail")
+	exitForegroundIfHealthlyPtr := flag.Bool("fg-exit-early-if-healthy", false, "When running in the foreground can exit early before graceperiod is over if health checks are ok")
+	launchTime := flag.Int64("launchtime", -1, "The launch time of the server that is running")
+	commandPtr := flag.String("command", "", "The command to run")
+	explainPtr := flag.Bool("explain", false, "run the healthchecks once, and explain how the policy evaluates against them")
+	maintenanceDurationPtr := flag.Duration("maintenance-duration", 0, "how long maintenance mode lasts, for -command maintenance-start (defaults to maintenance.duration)")
+	maintenanceReasonPtr := flag.String("maintenance-reason", "", "why the instance is in maintenance, for -command maintenance-start")
+	standalonePtr := flag.Bool("standalone", false, "run without the ec2 metadata service or AWS, reporting health with the configured local action")
+
+	flag.Parse()
+	runInForeground := *foregroundPtr
+	exitEarlyForHealthyStatus := *exitForegroundIfHealthlyPtr
+	checkfile := *checkfilePtr
 
 	conf, err := config.Load(checkfile)
 
@@ -300,11 +806,50 @@ func main() {
 		os.Exit(1)
 	}
 
+	if *commandPtr == "maintenance-start" || *commandPtr == "maintenance-end" {
+		status, err := MaintenanceCommand(*conf, *commandPtr, *maintenanceDurationPtr, *maintenanceReasonPtr)
+		if err != nil {
+			errlog.Println(status, "\nError: ", err)
+			os.Exit(1)
+		}
+		fmt.Println(status)
+		os.Exit(0)
+	}
+
+	if *explainPtr {
+		CreateChecks(*conf)
+		if err := StartServer(*conf); err != nil {
+			errlog.Println("Unable to start local endpoint: ", err)
+			os.Exit(1)
+		}
+		time.Sleep(time.Duration(CalculateMaxCheckWaitTime(conf.Checks)+1) * time.Second)
+		explanation, healthy := ExplainHealth()
+		fmt.Print(explanation)
+		if healthy {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	var launched time.Time
+	if *standalonePtr || conf.Standalone {
+		instanceAction = CreateLocalAction(conf.Action)
+	} else {
+		instanceAction, launched = CreateAWSAction(conf)
+	}
+
 	uptimeCalculationFunction := CreateUpdateCalculationFunction(*launchTime, conf.GracePeriod)
+	if !launched.IsZero() {
+		uptimeCalculationFunction = CreateLaunchTimeUptimeFunction(launched)
+	}
 
 	if runInForeground {
 		// Start the checks running
 		CreateChecks(*conf)
+		if err := StartServer(*conf); err != nil {
+			errlog.Println("Unable to start local endpoint: ", err)
+			os.Exit(1)
+		}
 		startTime := time.Now().Unix()
 		timeToWait := CalculateMaxCheckWaitTime(conf.Checks) + 1
 		// Do not check the result until grace is over
@@ -319,7 +864,8 @@ func main() {
 			time.Sleep(time.Duration(extra) * time.Second)
 		}
 
-		if len(defaultRegistry.CheckStatus()) > 0 {
+		logWarnings()
+		if len(unhealthyChecks()) > 0 {
 			os.Exit(1)
 		} else {
 			os.Exit(0)
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tevino/abool"
+	"github.com/tootedom/ec2-local-healthchecker/actions"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
 	"github.com/tootedom/ec2-local-healthchecker/config"
+	"github.com/tootedom/ec2-local-healthchecker/health"
+	"github.com/tootedom/ec2-local-healthchecker/policy"
 )
 
 // This tests GET request with passing in a parameter.
@@ -240,3 +252,308 @@ func TestChecksHealthAfterFailure(t *testing.T) {
 	time.Sleep(5 * time.Second)
 	assert.True(t, len(defaultRegistry.CheckStatus()) == 0)
 }
+
+func TestCalculateMaxCheckWaitTimeForEvaluators(t *testing.T) {
+	checks := map[string]config.Check{
+		"ratio": config.Check{
+			Frequency: 2 * time.Second,
+			Evaluator: config.Evaluator{Type: "ratio", Samples: 10, Ratio: 0.55},
+		},
+	}
+	assert.Equal(t, 12, CalculateMaxCheckWaitTime(checks))
+
+	checks["duration"] = config.Check{
+		Frequency: 5 * time.Second,
+		Evaluator: config.Evaluator{Type: "duration", Duration: time.Minute},
+	}
+	assert.Equal(t, 65, CalculateMaxCheckWaitTime(checks))
+}
+
+func TestCreateUpdaterWithOnlyRise(t *testing.T) {
+	failure := errors.New("down")
+	updater := CreateUpdater(config.Check{Rise: 3})
+
+	updater.Update(failure)
+	assert.Equal(t, failure, updater.Check())
+	updater.Update(nil)
+	assert.Error(t, updater.Check())
+	updater.Update(nil)
+	assert.Error(t, updater.Check())
+	updater.Update(nil)
+	assert.Nil(t, updater.Check())
+}
+
+func TestPolicyDecidesInstanceHealth(t *testing.T) {
+	passing := checks.CheckFunc(func() error { return nil })
+	failing := checks.CheckFunc(func() error { return errors.New("down") })
+
+	defaultRegistry = health.NewRegistry()
+	defaultRegistry.Register("nginx", passing)
+	defaultRegistry.Register("memcached", failing)
+	defaultRegistry.Register("redis", passing)
+	defer func() { instancePolicy = nil }()
+
+	assert.Len(t, unhealthyChecks(), 1)
+
+	p, err := policy.Parse("nginx && (memcached || redis)")
+	require.NoError(t, err)
+	instancePolicy = p
+	assert.Len(t, unhealthyChecks(), 0)
+
+	explanation, healthy := ExplainHealth()
+	assert.True(t, healthy)
+	assert.Contains(t, explanation, "FAIL  memcached: down")
+	assert.Contains(t, explanation, "PASS  memcached || redis")
+
+	p, err = policy.Parse("nginx && memcached")
+	require.NoError(t, err)
+	instancePolicy = p
+	unhealthy := unhealthyChecks()
+	assert.Equal(t, "down", unhealthy["memcached"])
+	assert.Contains(t, unhealthy, "policy")
+}
+
+func TestStatusReport(t *testing.T) {
+	conf := config.Config{
+		Listen: "127.0.0.1:0",
+		Checks: map[string]config.Check{
+			"worker": config.Check{Type: "passive", TTL: time.Minute},
+			"disk":   config.Check{Type: "exec", Command: "exit 1", Severity: "warning", Frequency: time.Hour},
+		},
+	}
+	CreateChecks(conf)
+	passiveChecks["worker"].Update(errors.New("queue backed up"))
+
+	report := CreateStatusReport(conf)
+	assert.False(t, report.Healthy)
+	assert.Equal(t, CheckReport{Result: "fail", Message: "queue backed up", Severity: "critical", Passive: true}, report.Checks["worker"])
+	assert.Equal(t, CheckReport{Result: "pass", Severity: "warning"}, report.Checks["disk"])
+}
+
+func TestLaunchTimeUptimeIsNotReset(t *testing.T) {
+	launched := time.Now().Add(-time.Hour)
+	uptime := CreateLaunchTimeUptimeFunction(launched)
+	assert.InDelta(t, 3600, uptime(), 1)
+}
+
+func TestNoActionInMaintenanceMode(t *testing.T) {
+	var reported []string
+	instanceAction = actions.ActionFunc(func(status string) error {
+		reported = append(reported, status)
+		return nil
+	})
+	instanceIsHealthy = abool.NewBool(true)
+	defer func() { maintenanceMode = nil }()
+
+	defaultRegistry = health.NewRegistry()
+	defaultRegistry.Register("nginx", checks.CheckFunc(func() error { return errors.New("down") }))
+	maintenanceMode = CreateMaintenance(config.Config{Maintenance: config.Maintenance{Duration: time.Hour}})
+	maintenanceMode.Start(0, "deploying", "test")
+
+	checkChecks()
+	assert.Empty(t, reported)
+	assert.Equal(t, "deploying", CreateStatusReport(config.Config{}).Maintenance.Reason)
+
+	require.NoError(t, maintenanceMode.End())
+	checkChecks()
+	assert.Equal(t, []string{actions.StatusUnhealthy}, reported)
+	assert.Nil(t, CreateStatusReport(config.Config{}).Maintenance)
+}
+
+func TestBlackoutIgnoresChecks(t *testing.T) {
+	defaultRegistry = health.NewRegistry()
+	defaultRegistry.Register("disk_io", checks.CheckFunc(func() error { return errors.New("slow") }))
+	defaultRegistry.Register("nginx", checks.CheckFunc(func() error { return nil }))
+	defer func() { blackouts = nil }()
+
+	assert.Len(t, unhealthyChecks(), 1)
+
+	conf := config.Config{
+		Blackouts: map[string]config.Blackout{
+			"backup": config.Blackout{Schedule: "* * * * *", Duration: time.Minute, Timezone: "UTC", Checks: []string{"disk_io"}},
+		},
+	}
+	blackouts = CreateBlackouts(conf)
+	assert.Len(t, unhealthyChecks(), 0)
+
+	report := CreateStatusReport(conf)
+	assert.True(t, report.Healthy)
+	assert.Equal(t, "backup", report.Checks["disk_io"].Blackout)
+	require.Len(t, report.Blackouts, 1)
+	assert.True(t, report.Blackouts[0].Active)
+
+	explanation, _ := ExplainHealth()
+	assert.Contains(t, explanation, "IGNORE  disk_io: in blackout window backup")
+}
+
+func TestBlackoutSuppressesActions(t *testing.T) {
+	var reported []string
+	instanceAction = actions.ActionFunc(func(status string) error {
+		reported = append(reported, status)
+		return nil
+	})
+	instanceIsHealthy = abool.NewBool(true)
+	defer func() { blackouts = nil }()
+
+	defaultRegistry = health.NewRegistry()
+	defaultRegistry.Register("nginx", checks.CheckFunc(func() error { return errors.New("down") }))
+	blackouts = CreateBlackouts(config.Config{
+		Blackouts: map[string]config.Blackout{
+			"deploys": config.Blackout{Schedule: "* * * * *", Duration: time.Minute},
+		},
+	})
+
+	checkChecks()
+	assert.Empty(t, reported)
+
+	blackouts = nil
+	checkChecks()
+	assert.Equal(t, []string{actions.StatusUnhealthy}, reported)
+}
+
+func TestCalculateMaxCheckWaitTimeForSchedules(t *testing.T) {
+	checks := map[string]config.Check{
+		"consistency": config.Check{Schedule: "*/15 * * * *", Threshold: 2},
+	}
+	assert.Equal(t, 1800, CalculateMaxCheckWaitTime(checks))
+}
+
+func TestCalculateMaxCheckWaitTimeForFailureFrequency(t *testing.T) {
+	checks := map[string]config.Check{
+		"nginx": config.Check{Frequency: 30 * time.Second, FailureFrequency: 5 * time.Second, Splay: 2 * time.Second, Threshold: 3},
+	}
+	assert.Equal(t, 42, CalculateMaxCheckWaitTime(checks))
+}
+
+func TestStatusReportTimeouts(t *testing.T) {
+	conf := config.Config{
+		Checks: map[string]config.Check{
+			"hung": config.Check{Type: "exec", Command: "exec sleep 5", Frequency: time.Hour, Immediate: true, Deadline: 100 * time.Millisecond, Threshold: 1},
+		},
+	}
+	CreateChecks(conf)
+	time.Sleep(300 * time.Millisecond)
+
+	report := CreateStatusReport(conf)
+	assert.Equal(t, CheckReport{Result: "timeout", Message: "timed out after 100ms", Severity: "critical", TimedOut: 1}, report.Checks["hung"])
+}
+
+func TestCreateTLSConfig(t *testing.T) {
+	tlsConfig, err := CreateTLSConfig(config.TLS{}, "127.0.0.1:443")
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	tlsConfig, err = CreateTLSConfig(config.TLS{Enabled: true}, "redis.internal:6380")
+	require.NoError(t, err)
+	assert.Equal(t, "redis.internal", tlsConfig.ServerName)
+
+	tlsConfig, err = CreateTLSConfig(config.TLS{Enabled: true, ServerName: "cache"}, "127.0.0.1:6380")
+	require.NoError(t, err)
+	assert.Equal(t, "cache", tlsConfig.ServerName)
+
+	_, err = CreateTLSConfig(config.TLS{Enabled: true, CAFile: "main.go"}, "127.0.0.1:6380")
+	assert.Error(t, err)
+}
+
+func TestCreateMemcachedOptions(t *testing.T) {
+	assert.Equal(t, checks.MemcachedOptions{MaxEvictions: -1, CanaryKey: "healthcheck"}, CreateMemcachedOptions(config.Memcached{Canary: "healthcheck"}))
+	evictions := int64(0)
+	assert.Equal(t, checks.MemcachedOptions{MaxEvictions: 0, MaxConnectionsPercent: 80}, CreateMemcachedOptions(config.Memcached{Evictions: &evictions, ConnectionsPercent: 80}))
+}
+
+func TestCreateCheckerUnixSocketHTTP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "healthchecker")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer listener.Close()
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "docker" || r.URL.Path != "/_ping" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	assert.NoError(t, CreateChecker(config.Check{Endpoint: "unix://" + socket, Path: "/_ping", Host: "docker", Timeout: time.Second}).Check())
+	assert.Error(t, CreateChecker(config.Check{Endpoint: "unix://" + socket, Timeout: time.Second}).Check())
+	assert.NoError(t, CreateChecker(config.Check{Type: "tcp", Endpoint: "unix://" + socket, Timeout: time.Second}).Check())
+}
+
+func TestCreateCheckerMemcachedTypeCase(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					if strings.HasPrefix(string(buf[:n]), "stats") {
+						fmt.Fprint(conn, "STAT curr_connections 10\r\nEND\r\n")
+					} else {
+						fmt.Fprint(conn, "ERROR\r\n")
+					}
+				}
+			}()
+		}
+	}()
+
+	// a redis checker would send PING, which memcached replies ERROR to
+	checker := CreateChecker(config.Check{Type: "Memcached", Endpoint: listener.Addr().String(), Timeout: time.Second})
+	assert.NoError(t, checker.Check())
+}
+
+func TestCreateCheckerPostgresTypeCase(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	err = CreateChecker(config.Check{Type: "Postgres", Endpoint: addr, Timeout: time.Second}).Check()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection to postgres at "+addr+" failed")
+	}
+}
+
+func TestCreateBlackoutsDefaultsToLocalTime(t *testing.T) {
+	windows := CreateBlackouts(config.Config{Blackouts: map[string]config.Blackout{
+		"backup": config.Blackout{Schedule: "0 2 * * *", Duration: time.Hour},
+	}})
+	require.Len(t, windows, 1)
+	assert.Equal(t, time.Local, windows[0].Location)
+}
+
+func TestMaintenanceEndpointOnlyServedLocally(t *testing.T) {
+	defer func() { maintenanceMode = nil }()
+	maintenanceMode = CreateMaintenance(config.Config{Maintenance: config.Maintenance{Duration: time.Hour}})
+
+	freePort := func() string {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		_, port, _ := net.SplitHostPort(listener.Addr().String())
+		return port
+	}
+	for listen, status := range map[string]int{
+		"127.0.0.1:" + freePort(): http.StatusOK,
+		"0.0.0.0:" + freePort():   http.StatusNotFound,
+	} {
+		require.NoError(t, StartServer(config.Config{Listen: listen}))
+		_, port, _ := net.SplitHostPort(listen)
+		response, err := http.Post("http://127.0.0.1:"+port+"/maintenance", "application/x-www-form-urlencoded", strings.NewReader("reason=test"))
+		require.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, status, response.StatusCode, listen)
+	}
+	maintenanceMode.End()
+}
@@ -0,0 +1,165 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package maintenance tracks whether the instance is in maintenance mode.
+// While it is, checks keep running but no actions are taken. Maintenance mode
+// is started by a flag file, or explicitly (by a signal or admin request),
+// and always expires.
+//
+// The flag file holds the expiry time (RFC3339) on its first line, and the
+// reason on the following lines. If the expiry is missing the file expires
+// the default duration after it was last modified.
+package maintenance
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is the maintenance mode state
+type State struct {
+	Active bool      `json:"active"`
+	Until  time.Time `json:"until,omitempty"`
+	Reason string    `json:"reason,omitempty"`
+	Source string    `json:"source,omitempty"`
+}
+
+// String describes the state
+func (s State) String() string {
+	if !s.Active {
+		return "not in maintenance"
+	}
+	return fmt.Sprintf("in maintenance until %s (%s): %s", s.Until.Format(time.RFC3339), s.Source, s.Reason)
+}
+
+// Manager tracks the maintenance mode state
+type Manager struct {
+	mu              sync.Mutex
+	file            string
+	defaultDuration time.Duration
+	now             func() time.Time
+	explicit        State
+	last            State
+	onChange        func(State)
+}
+
+// NewManager returns a Manager that reads the flag file at path, using the
+// default duration for maintenance started without an expiry
+func NewManager(path string, defaultDuration time.Duration) *Manager {
+	return &Manager{file: path, defaultDuration: defaultDuration, now: time.Now}
+}
+
+// OnChange sets the function that is called with the new state when
+// maintenance mode starts or ends
+func (m *Manager) OnChange(onChange func(State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = onChange
+}
+
+// Start starts maintenance mode for the duration, or the default duration
+// if it is zero
+func (m *Manager) Start(duration time.Duration, reason string, source string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if duration <= 0 {
+		duration = m.defaultDuration
+	}
+	m.explicit = State{Active: true, Until: m.now().Add(duration), Reason: reason, Source: source}
+	m.update()
+}
+
+// End ends maintenance mode, removing the flag file if it exists
+func (m *Manager) End() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.explicit = State{}
+	var err error
+	if m.file != "" {
+		if err = os.Remove(m.file); os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	m.update()
+	return err
+}
+
+// State returns the current state, which is active if either the flag file
+// or an explicit start has not expired
+func (m *Manager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.update()
+}
+
+// update recalculates the state, calling onChange if maintenance has started
+// or ended. It must be called with the lock held.
+func (m *Manager) update() State {
+	now := m.now()
+	state := State{}
+	if m.explicit.Active && now.Before(m.explicit.Until) {
+		state = m.explicit
+	}
+	if fileState, err := m.readFile(); err == nil && now.Before(fileState.Until) && fileState.Until.After(state.Until) {
+		state = fileState
+	}
+
+	changed := state.Active != m.last.Active
+	m.last = state
+	if changed && m.onChange != nil {
+		m.onChange(state)
+	}
+	return state
+}
+
+func (m *Manager) readFile() (State, error) {
+	if m.file == "" {
+		return State{}, os.ErrNotExist
+	}
+	info, err := os.Stat(m.file)
+	if err != nil {
+		return State{}, err
+	}
+	content, err := ioutil.ReadFile(m.file)
+	if err != nil {
+		return State{}, err
+	}
+	return parseFile(string(content), info.ModTime().Add(m.defaultDuration), m.file), nil
+}
+
+// parseFile parses the content of the flag file. If the first line is not an
+// expiry time, it is part of the reason and the default expiry is used.
+func parseFile(content string, defaultUntil time.Time, path string) State {
+	state := State{Active: true, Until: defaultUntil, Source: path}
+	lines := strings.SplitN(strings.TrimSpace(content), "\n", 2)
+	if until, err := time.Parse(time.RFC3339, strings.TrimSpace(lines[0])); err == nil {
+		state.Until = until
+		lines = lines[1:]
+	}
+	state.Reason = strings.TrimSpace(strings.Join(lines, "\n"))
+	return state
+}
+
+// WriteFile writes the flag file at path, starting maintenance mode until the
+// given time for any checker reading it
+func WriteFile(path string, until time.Time, reason string) error {
+	content := until.UTC().Format(time.RFC3339) + "\n" + reason + "\n"
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,96 @@
+package maintenance
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTime struct {
+	current time.Time
+}
+
+func (ft *fakeTime) now() time.Time {
+	return ft.current
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeTime, string, func()) {
+	dir, err := ioutil.TempDir("", "maintenance")
+	require.NoError(t, err)
+	clock := &fakeTime{current: time.Now()}
+	m := NewManager(filepath.Join(dir, "checker.maint"), time.Hour)
+	m.now = clock.now
+	return m, clock, m.file, func() { os.RemoveAll(dir) }
+}
+
+func TestExplicitMaintenanceExpires(t *testing.T) {
+	m, clock, _, cleanup := newTestManager(t)
+	defer cleanup()
+
+	var events []State
+	m.OnChange(func(state State) {
+		events = append(events, state)
+	})
+
+	assert.False(t, m.State().Active)
+	m.Start(0, "replacing disk", "signal")
+	state := m.State()
+	assert.True(t, state.Active)
+	assert.Equal(t, "replacing disk", state.Reason)
+	assert.Equal(t, clock.current.Add(time.Hour), state.Until)
+
+	clock.current = clock.current.Add(time.Hour)
+	assert.False(t, m.State().Active)
+
+	require.Len(t, events, 2)
+	assert.True(t, events[0].Active)
+	assert.False(t, events[1].Active)
+}
+
+func TestMaintenanceFile(t *testing.T) {
+	m, clock, path, cleanup := newTestManager(t)
+	defer cleanup()
+
+	until := clock.current.Add(30 * time.Minute).Truncate(time.Second)
+	require.NoError(t, WriteFile(path, until, "upgrading the jvm"))
+	state := m.State()
+	assert.True(t, state.Active)
+	assert.True(t, until.Equal(state.Until))
+	assert.Equal(t, "upgrading the jvm", state.Reason)
+	assert.Equal(t, path, state.Source)
+
+	clock.current = until
+	assert.False(t, m.State().Active)
+}
+
+func TestMaintenanceFileWithoutExpiryUsesDefault(t *testing.T) {
+	m, clock, path, cleanup := newTestManager(t)
+	defer cleanup()
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("manual work\non the box\n"), 0644))
+	state := m.State()
+	assert.True(t, state.Active)
+	assert.Equal(t, "manual work\non the box", state.Reason)
+
+	clock.current = clock.current.Add(2 * time.Hour)
+	assert.False(t, m.State().Active)
+}
+
+func TestEndRemovesFile(t *testing.T) {
+	m, clock, path, cleanup := newTestManager(t)
+	defer cleanup()
+
+	require.NoError(t, WriteFile(path, clock.current.Add(time.Hour), "work"))
+	m.Start(time.Hour, "more work", "api")
+	assert.True(t, m.State().Active)
+
+	require.NoError(t, m.End())
+	assert.False(t, m.State().Active)
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
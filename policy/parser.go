@@ -0,0 +1,201 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+type token struct {
+	text string
+	pos  int
+}
+
+// tokenize splits the expression into operators, parentheses, commas and
+// words (check names, numbers and function names). Anything else is returned
+// as a single character token so the parser can report it.
+func tokenize(expression string) []token {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case (r == '&' || r == '|') && i+1 < len(runes) && runes[i+1] == r:
+			tokens = append(tokens, token{text: string(runes[i : i+2]), pos: i})
+			i += 2
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{text: string(runes[start:i]), pos: start})
+		default:
+			tokens = append(tokens, token{text: string(r), pos: i})
+			i++
+		}
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.'
+}
+
+func isWord(text string) bool {
+	for _, r := range text {
+		if !isWordRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parser is a recursive descent parser for:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" or ")" | "atleast" "(" number { "," or } ")" | name
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].text
+	}
+	return ""
+}
+
+func (p *parser) expect(text string) error {
+	if p.pos >= len(p.tokens) {
+		return fmt.Errorf("expected %q at end of policy", text)
+	}
+	if p.tokens[p.pos].text != text {
+		return fmt.Errorf("expected %q but found %q at position %d", text, p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	operands := []node{first}
+	for p.peek() == "||" {
+		p.pos++
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &orNode{operands: operands}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	operands := []node{first}
+	for p.peek() == "&&" {
+		p.pos++
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, next)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return &andNode{operands: operands}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek() == "!" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of policy")
+	}
+	tok := p.tokens[p.pos]
+	switch {
+	case tok.text == "(":
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case tok.text == "atleast" && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].text == "(":
+		return p.parseAtLeast()
+	case isWord(tok.text):
+		p.pos++
+		return &nameNode{name: tok.text}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+func (p *parser) parseAtLeast() (node, error) {
+	p.pos += 2
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of policy")
+	}
+	tok := p.tokens[p.pos]
+	count, err := strconv.Atoi(tok.text)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("atleast requires a count but found %q at position %d", tok.text, tok.pos)
+	}
+	p.pos++
+	var operands []node
+	for p.peek() == "," {
+		p.pos++
+		operand, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if count > len(operands) {
+		return nil, fmt.Errorf("atleast(%d, ...) at position %d has only %d checks", count, tok.pos, len(operands))
+	}
+	return &atLeastNode{count: count, operands: operands}, nil
+}
@@ -0,0 +1,218 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package policy evaluates a boolean expression over the state of the
+// checks, to decide the health of the instance. For example:
+//
+//	nginx && (memcached || redis) && atleast(2, app1, app2, app3)
+//
+// A check name is true when the check is passing. The operators are &&, ||
+// and !, with the usual precedence, and atleast(n, ...) is true when at least
+// n of its arguments are true.
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Policy is a parsed policy expression
+type Policy struct {
+	source string
+	root   node
+}
+
+// Parse parses the policy expression
+func Parse(expression string) (*Policy, error) {
+	p := &parser{tokens: tokenize(expression)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("policy is empty")
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].pos)
+	}
+	return &Policy{source: expression, root: root}, nil
+}
+
+// String returns the policy expression
+func (p *Policy) String() string {
+	return p.source
+}
+
+// Names returns the sorted, unique check names referenced by the policy
+func (p *Policy) Names() []string {
+	seen := make(map[string]bool)
+	p.root.names(seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evaluate returns the result of the policy for the given check states, where
+// a state is true if the check is passing. Checks that are missing from the
+// states are not passing.
+func (p *Policy) Evaluate(states map[string]bool) bool {
+	return p.root.evaluate(states)
+}
+
+// Explain returns a description of how each part of the policy evaluates for
+// the given check states, one line per sub expression
+func (p *Policy) Explain(states map[string]bool) string {
+	var buf bytes.Buffer
+	p.root.explain(&buf, states, 0)
+	return buf.String()
+}
+
+type node interface {
+	evaluate(states map[string]bool) bool
+	names(seen map[string]bool)
+	explain(buf *bytes.Buffer, states map[string]bool, depth int)
+	String() string
+}
+
+func explainLine(buf *bytes.Buffer, n node, states map[string]bool, depth int) {
+	result := "FAIL"
+	if n.evaluate(states) {
+		result = "PASS"
+	}
+	fmt.Fprintf(buf, "%s%s  %s\n", strings.Repeat("  ", depth), result, n)
+}
+
+type nameNode struct {
+	name string
+}
+
+func (n *nameNode) evaluate(states map[string]bool) bool { return states[n.name] }
+func (n *nameNode) names(seen map[string]bool)           { seen[n.name] = true }
+func (n *nameNode) String() string                       { return n.name }
+func (n *nameNode) explain(buf *bytes.Buffer, states map[string]bool, depth int) {
+	explainLine(buf, n, states, depth)
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) evaluate(states map[string]bool) bool { return !n.operand.evaluate(states) }
+func (n *notNode) names(seen map[string]bool)           { n.operand.names(seen) }
+func (n *notNode) String() string                       { return "!" + wrap(n.operand) }
+func (n *notNode) explain(buf *bytes.Buffer, states map[string]bool, depth int) {
+	explainLine(buf, n, states, depth)
+	n.operand.explain(buf, states, depth+1)
+}
+
+type andNode struct {
+	operands []node
+}
+
+func (n *andNode) evaluate(states map[string]bool) bool {
+	for _, operand := range n.operands {
+		if !operand.evaluate(states) {
+			return false
+		}
+	}
+	return true
+}
+func (n *andNode) names(seen map[string]bool) { namesOf(n.operands, seen) }
+func (n *andNode) String() string             { return join(n.operands, " && ") }
+func (n *andNode) explain(buf *bytes.Buffer, states map[string]bool, depth int) {
+	explainLine(buf, n, states, depth)
+	explainAll(buf, n.operands, states, depth+1)
+}
+
+type orNode struct {
+	operands []node
+}
+
+func (n *orNode) evaluate(states map[string]bool) bool {
+	for _, operand := range n.operands {
+		if operand.evaluate(states) {
+			return true
+		}
+	}
+	return false
+}
+func (n *orNode) names(seen map[string]bool) { namesOf(n.operands, seen) }
+func (n *orNode) String() string             { return join(n.operands, " || ") }
+func (n *orNode) explain(buf *bytes.Buffer, states map[string]bool, depth int) {
+	explainLine(buf, n, states, depth)
+	explainAll(buf, n.operands, states, depth+1)
+}
+
+type atLeastNode struct {
+	count    int
+	operands []node
+}
+
+func (n *atLeastNode) evaluate(states map[string]bool) bool {
+	passing := 0
+	for _, operand := range n.operands {
+		if operand.evaluate(states) {
+			passing++
+		}
+	}
+	return passing >= n.count
+}
+func (n *atLeastNode) names(seen map[string]bool) { namesOf(n.operands, seen) }
+func (n *atLeastNode) String() string {
+	args := make([]string, 0, len(n.operands))
+	for _, operand := range n.operands {
+		args = append(args, operand.String())
+	}
+	return fmt.Sprintf("atleast(%d, %s)", n.count, strings.Join(args, ", "))
+}
+func (n *atLeastNode) explain(buf *bytes.Buffer, states map[string]bool, depth int) {
+	explainLine(buf, n, states, depth)
+	explainAll(buf, n.operands, states, depth+1)
+}
+
+func namesOf(nodes []node, seen map[string]bool) {
+	for _, n := range nodes {
+		n.names(seen)
+	}
+}
+
+func explainAll(buf *bytes.Buffer, nodes []node, states map[string]bool, depth int) {
+	for _, n := range nodes {
+		n.explain(buf, states, depth)
+	}
+}
+
+func join(nodes []node, separator string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		parts = append(parts, wrap(n))
+	}
+	return strings.Join(parts, separator)
+}
+
+// wrap returns the node in parentheses if it is a binary expression
+func wrap(n node) string {
+	switch n.(type) {
+	case *andNode, *orNode:
+		return "(" + n.String() + ")"
+	}
+	return n.String()
+}
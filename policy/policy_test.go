@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	p, err := Parse("nginx && (memcached || redis) && atleast(2, app1, app2, app3)")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app1", "app2", "app3", "memcached", "nginx", "redis"}, p.Names())
+
+	states := map[string]bool{"nginx": true, "memcached": false, "redis": true, "app1": true, "app2": false, "app3": true}
+	assert.True(t, p.Evaluate(states))
+
+	states["app3"] = false
+	assert.False(t, p.Evaluate(states))
+
+	states["app3"] = true
+	states["redis"] = false
+	assert.False(t, p.Evaluate(states))
+}
+
+func TestPrecedence(t *testing.T) {
+	p, err := Parse("a || b && !c")
+	require.NoError(t, err)
+	assert.Equal(t, "a || (b && !c)", p.root.String())
+	assert.True(t, p.Evaluate(map[string]bool{"a": true, "c": true}))
+	assert.True(t, p.Evaluate(map[string]bool{"b": true}))
+	assert.False(t, p.Evaluate(map[string]bool{"b": true, "c": true}))
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"nginx &&",
+		"nginx & redis",
+		"(nginx || redis",
+		"nginx redis",
+		"atleast(x, a, b)",
+		"atleast(3, a, b)",
+		"atleast(1 a)",
+		"nginx || )",
+	} {
+		_, err := Parse(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	p, err := Parse("nginx && (memcached || redis)")
+	require.NoError(t, err)
+	explanation := p.Explain(map[string]bool{"nginx": true, "redis": false})
+	assert.Equal(t, `FAIL  nginx && (memcached || redis)
+  PASS  nginx
+  FAIL  memcached || redis
+    FAIL  memcached
+    FAIL  redis
+`, explanation)
+}
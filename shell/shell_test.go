@@ -0,0 +1,28 @@
+package shell
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandPassesArgs(t *testing.T) {
+	output, err := Command(context.Background(), "echo $0 $1", "sh", "healthy").CombinedOutput()
+	require.NoError(t, err)
+	assert.Equal(t, "sh healthy\n", string(output))
+}
+
+func TestCommandKillsChildrenWhenDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+
+	// the shell waits for sleep, which holds the output pipe open
+	start := time.Now()
+	_, err := Command(ctx, "sleep 3; true").CombinedOutput()
+	assert.Error(t, err)
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err())
+	assert.True(t, time.Since(start) < 2*time.Second, "took %s", time.Since(start))
+}
@@ -0,0 +1,131 @@
+//
+// Copyright [2018] [Dominic Tootell]
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tootedom/ec2-local-healthchecker/blackout"
+	"github.com/tootedom/ec2-local-healthchecker/checks"
+	"github.com/tootedom/ec2-local-healthchecker/config"
+	"github.com/tootedom/ec2-local-healthchecker/health"
+	"github.com/tootedom/ec2-local-healthchecker/health/api"
+	"github.com/tootedom/ec2-local-healthchecker/maintenance"
+)
+
+// CheckReport is the status of a single check in the StatusReport
+type CheckReport struct {
+	Result   string `json:"result"`
+	Message  string `json:"message,omitempty"`
+	Severity string `json:"severity"`
+	Passive  bool   `json:"passive,omitempty"`
+	Blackout string `json:"blackout,omitempty"`
+	Skipped  int64  `json:"skipped,omitempty"`
+	TimedOut int64  `json:"timed_out,omitempty"`
+}
+
+// StatusReport is the status of the checker returned by GET /status
+type StatusReport struct {
+	Healthy     bool                   `json:"healthy"`
+	Policy      string                 `json:"policy,omitempty"`
+	Maintenance *maintenance.State     `json:"maintenance,omitempty"`
+	Blackouts   []blackout.Status      `json:"blackouts,omitempty"`
+	Checks      map[string]CheckReport `json:"checks"`
+}
+
+// checkResult returns the result of a check with the given status: pass,
+// warn, skip (blocked by a dependency), timeout or fail
+func checkResult(err error) string {
+	switch {
+	case err == nil:
+		return "pass"
+	case health.IsBlocked(err):
+		return "skip"
+	case checks.IsWarning(err):
+		return "warn"
+	case checks.IsTimeout(err):
+		return "timeout"
+	}
+	return "fail"
+}
+
+// CreateStatusReport returns the current status of the checks
+func CreateStatusReport(conf config.Config) StatusReport {
+	report := StatusReport{
+		Healthy: len(unhealthyChecks()) == 0,
+		Checks:  make(map[string]CheckReport),
+	}
+	if instancePolicy != nil {
+		report.Policy = instancePolicy.String()
+	}
+	if maintenanceMode != nil {
+		if state := maintenanceMode.State(); state.Active {
+			report.Maintenance = &state
+		}
+	}
+	now := time.Now()
+	if len(blackouts) > 0 {
+		report.Blackouts = blackouts.Status(now)
+	}
+	ignored := blackouts.IgnoredChecks(now)
+	for checkName, err := range defaultRegistry.Statuses() {
+		check := CheckReport{
+			Result:   checkResult(err),
+			Severity: string(health.SeverityCritical),
+		}
+		if err != nil {
+			check.Message = err.Error()
+		}
+		if conf.Checks[checkName].Severity == "warning" {
+			check.Severity = string(health.SeverityWarning)
+		}
+		_, check.Passive = passiveChecks[checkName]
+		check.Blackout = ignored[checkName]
+		check.Skipped = checkRuns[checkName].Skipped()
+		check.TimedOut = checkRuns[checkName].TimedOut()
+		report.Checks[checkName] = check
+	}
+	return report
+}
+
+// StartServer starts the local HTTP endpoint, if one is configured, for
+// passive checks to be updated and the status to be read
+func StartServer(conf config.Config) error {
+	if conf.Listen == "" {
+		return nil
+	}
+	listener, err := api.Listen(conf.Listen)
+	if err != nil {
+		return err
+	}
+	handler := api.NewHandler(passiveChecks, func() interface{} {
+		return CreateStatusReport(conf)
+	})
+	if maintenanceMode != nil {
+		if api.IsLocalAddress(conf.Listen) {
+			handler.HandleMaintenance(maintenanceMode)
+		} else {
+			stdlog.Printf("Not serving /maintenance on %s, as it is not a unix socket or loopback address", conf.Listen)
+		}
+	}
+	go func() {
+		errlog.Println("Local endpoint stopped: ", http.Serve(listener, handler))
+	}()
+	stdlog.Printf("Listening on %s", conf.Listen)
+	return nil
+}